@@ -4,18 +4,25 @@ go 1.16
 
 require (
 	github.com/apex/log v1.9.0
-	github.com/aws/aws-sdk-go-v2 v1.6.0
+	github.com/aws/aws-sdk-go-v2 v1.7.0
 	github.com/aws/aws-sdk-go-v2/config v1.1.1
+	github.com/aws/aws-sdk-go-v2/credentials v1.1.1
 	github.com/aws/aws-sdk-go-v2/service/autoscaling v1.1.1
+	github.com/aws/aws-sdk-go-v2/service/cloudformation v1.1.1
 	github.com/aws/aws-sdk-go-v2/service/cloudtrail v1.1.1
 	github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs v1.1.1
 	github.com/aws/aws-sdk-go-v2/service/ec2 v1.1.1
+	github.com/aws/aws-sdk-go-v2/service/ecr v1.1.1
 	github.com/aws/aws-sdk-go-v2/service/ecs v1.1.1
 	github.com/aws/aws-sdk-go-v2/service/efs v1.1.1
 	github.com/aws/aws-sdk-go-v2/service/elasticloadbalancing v1.1.1
 	github.com/aws/aws-sdk-go-v2/service/iam v1.1.1
 	github.com/aws/aws-sdk-go-v2/service/lambda v1.1.1
+	github.com/aws/aws-sdk-go-v2/service/organizations v1.1.1
 	github.com/aws/aws-sdk-go-v2/service/rds v1.1.1
+	github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi v1.4.0
+	github.com/aws/aws-sdk-go-v2/service/route53 v1.1.1
+	github.com/aws/aws-sdk-go-v2/service/s3 v1.2.0
 	github.com/aws/smithy-go v1.9.1
 	github.com/fatih/color v1.10.0
 	github.com/gruntwork-io/terratest v0.24.2
@@ -27,4 +34,5 @@ require (
 	github.com/stretchr/testify v1.7.0
 	github.com/zclconf/go-cty v1.7.1
 	gopkg.in/yaml.v2 v2.2.8
+	gopkg.in/yaml.v3 v3.0.0-20200605160147-a5ece683394c
 )
@@ -9,7 +9,12 @@ import (
 
 // UserConfirmedDeletion asks the user to confirm deletion of resources
 func UserConfirmedDeletion(r io.Reader) bool {
-	log.Info("Are you sure you want to delete these resources (cannot be undone)? Only YES will be accepted.")
+	return UserConfirmed(r, "Are you sure you want to delete these resources (cannot be undone)?")
+}
+
+// UserConfirmed asks the user to confirm the given question
+func UserConfirmed(r io.Reader, question string) bool {
+	log.Info(question + " Only YES will be accepted.")
 	fmt.Print(fmt.Sprintf("%23v", "Enter a value: "))
 
 	var response string
@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/apex/log"
+)
+
+// SelectItem is an item that the user can select, e.g. a resource to delete.
+type SelectItem struct {
+	// Group is the name of the group the item belongs to (e.g., the resource type).
+	Group string
+	Name  string
+}
+
+// UserSelected walks through the given items grouped by their group (in order of appearance) and asks
+// the user for each item whether to select it (y), or not (n), or to select (a) or not select (k)
+// the item and all remaining items of the group. It returns the indexes of the selected items.
+// If the input ends, the remaining items are not selected.
+func UserSelected(r io.Reader, items []SelectItem, question string) []int {
+	var groups []string
+
+	indexesByGroup := map[string][]int{}
+
+	for i, item := range items {
+		if _, ok := indexesByGroup[item.Group]; !ok {
+			groups = append(groups, item.Group)
+		}
+
+		indexesByGroup[item.Group] = append(indexesByGroup[item.Group], i)
+	}
+
+	log.Info(question + " Answer with y (yes), n (no), a (yes to all remaining of this type) " +
+		"or k (no to all remaining of this type).")
+
+	var result []int
+
+	for _, group := range groups {
+		indexes := indexesByGroup[group]
+
+		LogTitle(fmt.Sprintf("%s: %d", group, len(indexes)))
+
+	Items:
+		for j, i := range indexes {
+			answer, ok := askItem(r, items[i].Name)
+			if !ok {
+				return result
+			}
+
+			switch answer {
+			case "y":
+				result = append(result, i)
+			case "a":
+				result = append(result, indexes[j:]...)
+				break Items
+			case "k":
+				break Items
+			}
+		}
+	}
+
+	return result
+}
+
+// askItem asks the user about an item until a valid answer is given. It returns false if the input ends.
+func askItem(r io.Reader, name string) (string, bool) {
+	for {
+		fmt.Printf("%23v", name+" [y,n,a,k]: ")
+
+		var response string
+
+		_, err := fmt.Fscanln(r, &response)
+		if err == io.EOF {
+			fmt.Println()
+			return "", false
+		}
+
+		switch response {
+		case "y", "n", "a", "k":
+			return response, true
+		}
+
+		log.Warn("Please answer with y, n, a or k.")
+	}
+}
@@ -0,0 +1,52 @@
+package internal_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jckuester/awsweeper/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserSelected(t *testing.T) {
+	items := []internal.SelectItem{
+		{Group: "aws_instance", Name: "i-1"},
+		{Group: "aws_vpc", Name: "vpc-1"},
+		{Group: "aws_instance", Name: "i-2"},
+		{Group: "aws_instance", Name: "i-3"},
+		{Group: "aws_vpc", Name: "vpc-2"},
+	}
+
+	tests := []struct {
+		name      string
+		userInput string
+		expected  []int
+	}{
+		{
+			name:      "select each item",
+			userInput: "y\nn\ny\nn\ny\n",
+			expected:  []int{0, 3, 4},
+		},
+		{
+			name:      "select all remaining of a group",
+			userInput: "n\na\nk\n",
+			expected:  []int{2, 3},
+		},
+		{
+			name:      "invalid answer is repeated",
+			userInput: "yes\ny\nk\ny\nn\n",
+			expected:  []int{0, 1},
+		},
+		{
+			name:      "input ends",
+			userInput: "y\n",
+			expected:  []int{0},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := internal.UserSelected(strings.NewReader(tc.userInput), items, "Delete?")
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/jckuester/awstools-lib/terraform"
 	"github.com/jckuester/awsweeper/internal"
 	"github.com/jckuester/awsweeper/pkg/resource"
+	"github.com/jckuester/terradozer/pkg/provider"
 	terradozerRes "github.com/jckuester/terradozer/pkg/resource"
 	flag "github.com/spf13/pflag"
 )
@@ -26,14 +27,59 @@ func main() {
 	os.Exit(mainExitCode())
 }
 
+// Exit codes of a run. If several apply, the first one in the order aborted, deletion failed,
+// listing failed, nothing matched is returned.
+const (
+	// exitCodeOK means that all matched resources have been deleted (or listed in dry-run mode).
+	exitCodeOK = 0
+	// exitCodeError means an invalid usage or a failed setup (e.g. of the AWS clients).
+	exitCodeError = 1
+	// exitCodeNothingMatched means that no resources matched the filter.
+	exitCodeNothingMatched = 3
+	// exitCodeDeleteFailed means that some matched resources failed to be deleted (or marked).
+	exitCodeDeleteFailed = 4
+	// exitCodeListFailed means that errors occurred while listing resources,
+	// so not all resources matching the filter might have been found.
+	exitCodeListFailed = 5
+	// exitCodeTooManyResources means that nothing has been deleted, since more resources matched than allowed
+	// (see --max-deletions).
+	exitCodeTooManyResources = 6
+	// exitCodeAborted means that the run has been aborted (via Ctrl+C or by not confirming the deletion).
+	exitCodeAborted = 130
+)
+
+const (
+	// providerVersion is the version of the Terraform AWS Provider that is used to list and delete resources.
+	providerVersion    = "v3.42.0"
+	providerInstallDir = "~/.awsweeper"
+)
+
 func mainExitCode() int {
+	var allRegions bool
 	var dryRun bool
 	var force bool
+	var gracePeriod time.Duration
+	var interactive bool
+	var listParallel int
 	var logDebug bool
+	var mark bool
+	var maxDeletions int
+	var maxRounds int
+	var orgAccounts string
+	var orgOU string
+	var orgRole string
 	var outputType string
 	var parallel int
+	var planMaxAge time.Duration
+	var planOut string
 	var profile string
+	var protectFile string
+	var profiles []string
 	var region string
+	var reportPath string
+	var strict bool
+	var sweep bool
+	var regions []string
 	var timeout string
 	var version bool
 
@@ -48,17 +94,50 @@ func mainExitCode() int {
 	flags.BoolVar(&logDebug, "debug", false, "Enable debug logging")
 	flags.StringVarP(&profile, "profile", "p", "", "The AWS profile for the account to delete resources in")
 	flags.StringVarP(&region, "region", "r", "", "The region to delete resources in")
+	flags.StringSliceVar(&profiles, "profiles", nil,
+		"The AWS profiles for the accounts to delete resources in (repeatable or comma-separated)")
+	flags.StringSliceVar(&regions, "regions", nil, "The regions to delete resources in (repeatable or comma-separated)")
+	flags.BoolVar(&allRegions, "all-regions", false, "Delete resources in all regions enabled for each account")
+	flags.StringVar(&orgRole, "org-role", "",
+		"Delete resources in the member accounts of an AWS Organization by assuming the role with this name")
+	flags.StringVar(&orgOU, "org-ou", "",
+		"Only delete resources in member accounts within this organizational unit (requires --org-role)")
+	flags.StringVar(&orgAccounts, "org-accounts", "",
+		"Only delete resources in member accounts whose ID or name match this regex (requires --org-role)")
 	flags.IntVar(&parallel, "parallel", 10, "Limit the number of concurrent delete operations")
+	flags.IntVar(&listParallel, "list-parallel", 10,
+		"Limit the number of concurrent list operations (each lists a resource type in an account and region)")
+	flags.IntVar(&maxDeletions, "max-deletions", 0,
+		"Abort without deleting anything if more matched resources than this would be deleted (0 means unlimited)")
+	flags.IntVar(&maxRounds, "max-rounds", 5,
+		"Maximum number of rounds to retry deleting resources that failed (e.g., due to dependencies)")
 	flags.BoolVar(&version, "version", false, "Show application version")
 	flags.BoolVar(&force, "force", false, "Delete without asking for confirmation")
+	flags.BoolVarP(&interactive, "interactive", "i", false,
+		"Decide for each matched resource whether to delete or keep it before confirming the deletion")
 	flags.StringVar(&timeout, "timeout", "30s", "Amount of time to wait for a destroy of a resource to finish")
+	flags.StringVar(&protectFile, "protect-file", "",
+		"A YAML file with a protect section listing resources that must never be deleted")
+	flags.BoolVar(&mark, "mark", false,
+		"Don't delete anything, but tag matching resources with "+resource.MarkTagKey+"=<timestamp>")
+	flags.BoolVar(&sweep, "sweep", false,
+		"Only delete matching resources that have been marked for deletion longer than the grace period")
+	flags.DurationVar(&gracePeriod, "grace-period", 7*24*time.Hour,
+		"Amount of time a resource must have been marked for deletion before it is deleted (sweep mode)")
+	flags.BoolVar(&strict, "strict", false,
+		"Fail if a filter criterion cannot be evaluated for a resource type or resource (e.g., tags or creation date)")
+	flags.StringVar(&reportPath, "report", "",
+		"Write a JSON report with the outcome of every matched resource to this file")
+	flags.StringVarP(&planOut, "out", "o", "plan.json", "The file to write the plan to (plan command)")
+	flags.DurationVar(&planMaxAge, "plan-max-age", 24*time.Hour,
+		"Refuse to apply a plan that is older than this (apply command)")
 
 	err := flags.Parse(os.Args[1:])
 	if err != nil {
 		// the Parse() function prints already an error + help message,
 		// so we don't want to output it here again
 		log.WithError(err).Debug("failed to parse command line arguments")
-		return 1
+		return exitCodeError
 	}
 
 	args := flags.Args()
@@ -77,68 +156,73 @@ func mainExitCode() int {
 
 	if version {
 		fmt.Println(internal.BuildVersionString())
-		return 0
+		return exitCodeOK
 	}
 
 	if force && dryRun {
 		fmt.Fprint(os.Stderr, color.RedString("Error:️ -force and -dry-run flag cannot be used together\n"))
 		printHelp(flags)
 
-		return 1
+		return exitCodeError
 	}
 
-	if len(args) == 0 {
-		fmt.Fprint(os.Stderr, color.RedString("Error: path to YAML filter expected\n"))
+	if interactive && (force || dryRun) {
+		fmt.Fprint(os.Stderr, color.RedString("Error:️ --interactive flag cannot be used with --force or --dry-run\n"))
 		printHelp(flags)
 
-		return 1
+		return exitCodeError
 	}
 
-	pathToFilter := args[0]
-
-	filter, err := resource.NewFilter(pathToFilter)
-	if err != nil {
-		fmt.Fprint(os.Stderr, color.RedString("Error: failed to create resource filter: %s\n", err))
-		return 1
+	var command string
+	if len(args) > 0 && (args[0] == "plan" || args[0] == "apply" || args[0] == "validate") {
+		command = args[0]
+		args = args[1:]
 	}
 
-	err = filter.Validate()
-	if err != nil {
-		fmt.Fprint(os.Stderr, color.RedString("Error: invalid filter: %s\n", err))
-		return 1
+	if mark && (sweep || command != "") {
+		fmt.Fprint(os.Stderr, color.RedString("Error:️ --mark flag cannot be used with --sweep, plan, apply or validate\n"))
+		printHelp(flags)
+
+		return exitCodeError
 	}
 
-	var profiles []string
-	var regions []string
+	if sweep && command == "apply" {
+		fmt.Fprint(os.Stderr, color.RedString("Error:️ --sweep flag cannot be used with apply\n"))
+		printHelp(flags)
 
-	if profile != "" {
-		profiles = []string{profile}
-	} else {
-		env, ok := os.LookupEnv("AWS_PROFILE")
-		if ok {
-			profiles = []string{env}
+		return exitCodeError
+	}
+
+	if len(args) == 0 {
+		if command == "apply" {
+			fmt.Fprint(os.Stderr, color.RedString("Error: path to plan expected\n"))
+		} else {
+			fmt.Fprint(os.Stderr, color.RedString("Error: path to YAML filter expected\n"))
 		}
+		printHelp(flags)
+
+		return exitCodeError
 	}
 
-	if region != "" {
-		regions = []string{region}
+	if command == "validate" {
+		return validate(args[0], outputType)
 	}
 
 	timeoutDuration, err := time.ParseDuration(timeout)
 	if err != nil {
 		log.WithError(err).Error("failed to parse timeout")
-		return 1
+		return exitCodeError
 	}
 
-	ctx := context.Background()
-
-	clients, err := aws.NewClientPool(ctx, profiles, regions)
-	if err != nil {
-		fmt.Fprint(os.Stderr, color.RedString("\nError: %s\n", err))
+	if !resource.IsSupportedOutputType(outputType) {
+		fmt.Fprint(os.Stderr, color.RedString("Error:️ unsupported output type: %s\n", outputType))
+		printHelp(flags)
 
-		return 1
+		return exitCodeError
 	}
 
+	ctx := context.Background()
+
 	// trap Ctrl+C and call cancel on the context
 	ctx, cancel := context.WithCancel(ctx)
 	signalCh := make(chan os.Signal, 1)
@@ -157,18 +241,134 @@ func mainExitCode() int {
 		}
 	}()
 
-	clientKeys := make([]aws.ClientKey, 0, len(clients))
-	for k := range clients {
-		clientKeys = append(clientKeys, k)
+	if profile != "" {
+		profiles = append([]string{profile}, profiles...)
+	} else if len(profiles) == 0 {
+		env, ok := os.LookupEnv("AWS_PROFILE")
+		if ok {
+			profiles = []string{env}
+		}
 	}
 
-	// initialize a Terraform AWS provider for each AWS client with a matching config
-	providers, err := terraform.NewProviderPool(ctx, clientKeys, "v3.42.0", "~/.awsweeper", timeoutDuration)
-	if err != nil {
-		if !errors.Is(err, context.Canceled) {
+	if region != "" {
+		regions = append([]string{region}, regions...)
+	}
+
+	if allRegions && len(regions) > 0 {
+		fmt.Fprint(os.Stderr, color.RedString("Error:️ --all-regions and --region(s) flag cannot be used together\n"))
+		printHelp(flags)
+
+		return exitCodeError
+	}
+
+	if orgRole == "" && (orgOU != "" || orgAccounts != "") {
+		fmt.Fprint(os.Stderr, color.RedString("Error:️ --org-ou and --org-accounts flag require --org-role\n"))
+		printHelp(flags)
+
+		return exitCodeError
+	}
+
+	var org *resource.Organization
+	if orgRole != "" {
+		if len(profiles) > 1 {
+			fmt.Fprint(os.Stderr, color.RedString("Error:️ --org-role and --profiles flag cannot be used together\n"))
+			printHelp(flags)
+
+			return exitCodeError
+		}
+
+		var orgProfile string
+		if len(profiles) == 1 {
+			orgProfile = profiles[0]
+		}
+
+		org, err = resource.NewOrganization(ctx, orgProfile, orgRole)
+		if err != nil {
 			fmt.Fprint(os.Stderr, color.RedString("\nError: %s\n", err))
+			return exitCodeError
+		}
+	}
+
+	var protection resource.Protection
+	if protectFile != "" {
+		protection, err = resource.NewProtection(protectFile)
+		if err != nil {
+			fmt.Fprint(os.Stderr, color.RedString("Error: failed to read protect file: %s\n", err))
+			return exitCodeError
+		}
+
+		err = protection.Validate()
+		if err != nil {
+			fmt.Fprint(os.Stderr, color.RedString("Error: invalid protect file: %s\n", err))
+			return exitCodeError
+		}
+	}
+
+	opts := runOptions{
+		force:       force,
+		dryRun:      dryRun,
+		interactive: interactive,
+		parallel:    parallel,
+		maxRounds:   maxRounds,
+		limits:      resource.Limits{Total: maxDeletions},
+		report:      resource.NewReport(dryRun),
+		reportPath:  reportPath,
+	}
+
+	if command == "apply" {
+		return apply(ctx, org, protection, args[0], planMaxAge, timeoutDuration, outputType, opts)
+	}
+
+	pathToFilter := args[0]
+
+	cfg, err := resource.NewConfig(pathToFilter)
+	if err != nil {
+		fmt.Fprint(os.Stderr, color.RedString("Error: failed to create resource filter: %s\n", err))
+		return opts.finish(exitCodeError)
+	}
+
+	cfg.Strict = cfg.Strict || strict
+
+	err = cfg.Validate()
+	if err != nil {
+		fmt.Fprint(os.Stderr, color.RedString("Error: invalid filter: %s\n", err))
+		return opts.finish(exitCodeError)
+	}
+
+	for _, u := range cfg.Filter.Unsupported() {
+		log.Warn(u.Error())
+	}
+
+	if len(cfg.ExcludeTerraformState) > 0 {
+		log.Infof("excluding %d resources managed by Terraform", cfg.Managed.Len())
+	}
+
+	cfg.Protect = append(cfg.Protect, protection...)
+
+	opts.limits.ByType = cfg.MaxDeletions
+
+	if sweep {
+		markedBefore := time.Now().Add(-gracePeriod)
+		cfg.MarkedBefore = &markedBefore
+	}
+
+	var clients map[aws.ClientKey]aws.Client
+	var providers map[aws.ClientKey]provider.TerraformProvider
+
+	if org != nil {
+		clients, providers, cfg.AccountNames, err = newOrganizationPools(ctx, org, orgOU, orgAccounts, regions,
+			allRegions, timeoutDuration)
+	} else {
+		clients, providers, err = newPools(ctx, profiles, regions, allRegions, timeoutDuration)
+	}
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return opts.finish(exitCodeAborted)
 		}
-		return 1
+
+		fmt.Fprint(os.Stderr, color.RedString("\nError: %s\n", err))
+
+		return opts.finish(exitCodeError)
 	}
 
 	defer func() {
@@ -177,46 +377,518 @@ func mainExitCode() int {
 		}
 	}()
 
-	internal.LogTitle("showing resources that would be deleted (dry run)")
-	var resources []terradozerRes.DestroyableResource
+	if mark {
+		internal.LogTitle("showing resources that would be marked for deletion (dry run)")
+	} else {
+		internal.LogTitle("showing resources that would be deleted (dry run)")
+	}
+
+	var listResult resource.ListResult
 
-	resourcesCh := make(chan []terradozerRes.DestroyableResource, 1)
+	doneList := make(chan bool, 1)
 	go func() {
-		resourcesCh <- resource.List(context.Background(), filter, clients, providers, outputType)
+		listResult = resource.List(ctx, cfg, clients, providers, outputType, listParallel)
+		doneList <- true
 	}()
 	select {
 	case <-ctx.Done():
-		return 1
-	case result := <-resourcesCh:
-		resources = result
+		return opts.finish(exitCodeAborted)
+	case <-doneList:
+	}
+
+	resources := listResult.Resources
+
+	opts.report.Add(resource.OutcomeProtected, listResult.Protected...)
+	opts.parents = listResult.Parents
+
+	if len(listResult.Errors) > 0 {
+		opts.listFailed = true
+
+		internal.LogTitle(fmt.Sprintf("errors occurred while listing resources: %d", len(listResult.Errors)))
+
+		for _, err := range listResult.Errors {
+			fmt.Fprint(os.Stderr, color.RedString("Error: %s\n", err))
+		}
+	}
+
+	logStackOwned(listResult.StackOwned, resources)
+
+	if cfg.Strict && hasCriterionError(listResult.Errors) {
+		fmt.Fprint(os.Stderr, color.RedString("Error: filter criteria cannot be evaluated for all resources (strict mode)\n"))
+		opts.report.Add(resource.OutcomeSkipped, resources...)
+
+		return opts.finish(exitCodeListFailed)
+	}
+
+	if command == "plan" {
+		opts.report.Add(resource.OutcomeSkipped, resources...)
+
+		plan, err := resource.NewPlan(resources, providerVersion)
+		if err != nil {
+			fmt.Fprint(os.Stderr, color.RedString("Error: failed to create plan: %s\n", err))
+			return opts.finish(exitCodeError)
+		}
+
+		err = plan.Write(planOut)
+		if err != nil {
+			fmt.Fprint(os.Stderr, color.RedString("Error: failed to write plan: %s\n", err))
+			return opts.finish(exitCodeError)
+		}
+
+		internal.LogTitle(fmt.Sprintf("wrote plan with %d resources to %s", len(plan.Resources), planOut))
+
+		if len(resources) == 0 {
+			return opts.finish(exitCodeNothingMatched)
+		}
+
+		return opts.finish(exitCodeOK)
+	}
+
+	if !mark && !opts.dryRun && !opts.force {
+		stacks, protectedStacks := selectOwningStacks(listResult.StackOwned, resources, providers, cfg)
+		opts.report.Add(resource.OutcomeProtected, protectedStacks...)
+
+		resources = append(resources, stacks...)
+	}
+
+	if mark {
+		taggingClients, err := newTaggingClients(ctx, org, clients)
+		if err != nil {
+			fmt.Fprint(os.Stderr, color.RedString("\nError: %s\n", err))
+			return opts.finish(exitCodeError)
+		}
+
+		return runMark(ctx, resources, taggingClients, opts)
+	}
+
+	if cfg.EmptyBuckets && opts.dryRun {
+		logBucketContents(ctx, resources, clients)
+	}
+
+	return runDelete(ctx, resources, opts)
+}
+
+// logBucketContents shows the content of the given S3 buckets that would be deleted before the buckets
+// (only in dry-run mode, since all objects of a bucket need to be listed).
+func logBucketContents(ctx context.Context, resources []terraform.Resource, clients map[aws.ClientKey]aws.Client) {
+	var buckets []terraform.Resource
+	for _, r := range resources {
+		if r.Type == "aws_s3_bucket" {
+			buckets = append(buckets, r)
+		}
+	}
+
+	if len(buckets) == 0 {
+		return
+	}
+
+	internal.LogTitle(fmt.Sprintf("content of buckets that would be emptied: %d", len(buckets)))
+
+	for _, r := range buckets {
+		client, ok := clients[aws.ClientKey{Profile: r.Profile, Region: r.Region}]
+		if !ok {
+			continue
+		}
+
+		content, err := resource.ReadBucketContent(ctx, client.S3conn, r.ID)
+		if err != nil {
+			log.WithError(err).WithField("id", r.ID).Warn(internal.Pad(r.Type) + "failed to read content")
+		} else {
+			log.WithField("id", r.ID).Info(internal.Pad(r.Type) + content.String())
+		}
+	}
+}
+
+// logStackOwned warns about resources that are skipped, since they are owned by a CloudFormation stack,
+// and whether their stack is deleted instead.
+func logStackOwned(stackOwned, selected []terraform.Resource) {
+	stacks := resource.OwningStacks(stackOwned, selected)
+	if len(stacks) == 0 {
+		return
+	}
+
+	internal.LogTitle(fmt.Sprintf("skipped resources owned by CloudFormation stacks: %d", len(stackOwned)))
+
+	for _, stack := range stacks {
+		logger := log.WithFields(log.Fields{
+			"stack":     stack.Name,
+			"resources": len(stack.Resources),
+			"account":   stack.AccountRegion.String(),
+		})
+
+		if stack.Selected {
+			logger.Warn("stack matches the filter and is deleted instead")
+		} else {
+			logger.Warn("add aws_cloudformation_stack to the filter to delete the stack " +
+				"(or set include_cloudformation to delete its resources individually)")
+		}
+	}
+}
+
+// selectOwningStacks offers to delete the CloudFormation stacks owning skipped resources that don't match the filter
+// themselves. Like listed resources, stacks that are protected or managed by Terraform are never offered.
+// It returns the stacks the user has chosen to delete instead of their resources and the ones that are protected.
+func selectOwningStacks(stackOwned, selected []terraform.Resource,
+	providers map[aws.ClientKey]provider.TerraformProvider, cfg *resource.Config) ([]terraform.Resource,
+	[]terraform.Resource) {
+	var result, protected []terraform.Resource
+
+	for _, stack := range resource.OwningStacks(stackOwned, selected) {
+		if stack.Selected {
+			continue
+		}
+
+		r, err := stack.Resource(providers)
+		if err != nil {
+			log.WithError(err).WithField("stack", stack.Name).Warn("failed to read stack")
+			continue
+		}
+
+		if cfg.Managed.Contains(r) {
+			log.WithField("stack", stack.Name).Info("stack is managed by Terraform and is not deleted")
+			continue
+		}
+
+		if cfg.Protect.Match(r) {
+			log.WithField("stack", stack.Name).Info("stack is protected and is not deleted")
+			protected = append(protected, r)
+			continue
+		}
+
+		if !internal.UserConfirmed(os.Stdin, fmt.Sprintf("Do you want to delete the CloudFormation stack %s (%s), "+
+			"which owns %d skipped resource(s)?", stack.Name, stack.AccountRegion, len(stack.Resources))) {
+			continue
+		}
+
+		log.WithField("id", r.ID).Info(internal.Pad(r.Type))
+
+		result = append(result, r)
+	}
+
+	return result, protected
+}
+
+// hasCriterionError returns true if any of the errors is caused by a filter criterion that cannot be evaluated.
+func hasCriterionError(errs []resource.ListError) bool {
+	for _, err := range errs {
+		var criterionErr resource.CriterionError
+		if errors.As(err, &criterionErr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// runOptions are the options to delete (or mark) resources.
+type runOptions struct {
+	force  bool
+	dryRun bool
+	// interactive lets the user select the resources to delete
+	interactive bool
+	parallel    int
+	maxRounds   int
+	// limits are the maximum numbers of resources that may be deleted
+	limits resource.Limits
+	// report collects the outcome of every matched resource
+	report     *resource.Report
+	reportPath string
+	// listFailed is set if errors occurred while listing resources
+	listFailed bool
+	// parents are the resources that the resources of dependent types belong to
+	parents resource.Parents
+}
+
+// exitCode returns the exit code of a run that finished with the given exit code,
+// taking listing errors into account.
+func (o runOptions) exitCode(exitCode int) int {
+	if o.listFailed && (exitCode == exitCodeOK || exitCode == exitCodeNothingMatched) {
+		return exitCodeListFailed
+	}
+
+	return exitCode
+}
+
+// finish writes the report (if a path is set) and returns the exit code of a run that finished with
+// the given exit code.
+func (o runOptions) finish(exitCode int) int {
+	if o.reportPath != "" {
+		err := o.report.Write(o.reportPath)
+		if err != nil {
+			fmt.Fprint(os.Stderr, color.RedString("Error: failed to write report: %s\n", err))
+
+			if exitCode == exitCodeOK {
+				return exitCodeError
+			}
+		}
+	}
+
+	return o.exitCode(exitCode)
+}
+
+// runMark tags the given resources to be deleted by a later sweep after the grace period.
+func runMark(ctx context.Context, resources []terraform.Resource, clients map[aws.ClientKey]resource.TaggingAPI,
+	opts runOptions) int {
+	if len(resources) == 0 {
+		internal.LogTitle("no resources found to mark for deletion")
+		return opts.finish(exitCodeNothingMatched)
+	}
+
+	logSummary("total number of resources that would be marked for deletion: %d", resources)
+
+	if opts.dryRun {
+		opts.report.Add(resource.OutcomeSkipped, resources...)
+		return opts.finish(exitCodeOK)
+	}
+
+	if !opts.force {
+		if !internal.UserConfirmed(os.Stdin, "Are you sure you want to mark these resources for deletion?") {
+			opts.report.Add(resource.OutcomeSkipped, resources...)
+			return opts.finish(exitCodeAborted)
+		}
+	} else {
+		internal.LogTitle("Proceeding with marking and skipping confirmation (force)")
+	}
+
+	result := resource.Mark(ctx, resources, clients, time.Now(), opts.parallel)
+
+	opts.report.Add(resource.OutcomeMarked, result.Marked...)
+	opts.report.Add(resource.OutcomeSkipped, result.AlreadyMarked...)
+	opts.report.Add(resource.OutcomeSkipped, result.Unsupported...)
+
+	for _, err := range result.Failed {
+		opts.report.AddWithError(err.Resource, resource.OutcomeFailed, err.Err)
+	}
+
+	if len(result.Unsupported) > 0 {
+		internal.LogTitle(fmt.Sprintf("resources that cannot be marked (no tag support or ARN): %d",
+			len(result.Unsupported)))
+
+		for _, r := range result.Unsupported {
+			log.WithField("id", r.ID).Warn(internal.Pad(r.Type))
+		}
+	}
+
+	if len(result.Failed) > 0 {
+		internal.LogTitle(fmt.Sprintf("failed to mark the following resources: %d", len(result.Failed)))
+
+		for _, err := range result.Failed {
+			log.WithError(err).WithField("id", err.Resource.ID).Warn(internal.Pad(err.Resource.Type))
+		}
+	}
+
+	if len(result.AlreadyMarked) > 0 {
+		internal.LogTitle(fmt.Sprintf("number of resources already marked before: %d", len(result.AlreadyMarked)))
+	}
+
+	logSummary("total number of marked resources: %d", result.Marked)
+
+	if len(result.Failed) > 0 {
+		return opts.finish(exitCodeDeleteFailed)
+	}
+
+	return opts.finish(exitCodeOK)
+}
+
+// newPools creates an AWS client and a Terraform AWS Provider for each combination of the given profiles and regions.
+func newPools(ctx context.Context, profiles, regions []string, allRegions bool, timeout time.Duration) (
+	map[aws.ClientKey]aws.Client, map[aws.ClientKey]provider.TerraformProvider, error) {
+	clients, err := resource.NewClientPool(ctx, profiles, regions, allRegions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	clientKeys := make([]aws.ClientKey, 0, len(clients))
+	for k := range clients {
+		clientKeys = append(clientKeys, k)
+	}
+
+	// initialize a Terraform AWS provider for each AWS client with a matching config
+	providers, err := terraform.NewProviderPool(ctx, clientKeys, providerVersion, providerInstallDir, timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return clients, providers, nil
+}
+
+// newOrganizationPools creates an AWS client and a Terraform AWS Provider for each combination of
+// the member accounts of an organization and the given regions. The names of the member accounts are returned
+// as well.
+func newOrganizationPools(ctx context.Context, org *resource.Organization, ou, accountPattern string,
+	regions []string, allRegions bool, timeout time.Duration) (
+	map[aws.ClientKey]aws.Client, map[aws.ClientKey]provider.TerraformProvider, resource.AccountNames, error) {
+	accounts, err := org.ListAccounts(ctx, ou, accountPattern)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if len(accounts) == 0 {
+		return nil, nil, nil, fmt.Errorf("no member accounts found in organization")
+	}
+
+	clients, err := org.NewClientPool(ctx, accounts, regions, allRegions)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	accountIDs := map[aws.ClientKey]string{}
+	for k, c := range clients {
+		accountIDs[k] = c.AccountID
+	}
+
+	providers, err := org.NewProviderPool(ctx, accountIDs, providerVersion, providerInstallDir, timeout)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return clients, providers, resource.NewAccountNames(accounts), nil
+}
+
+// newTaggingClients creates a Resource Groups Tagging API client for each of the given AWS clients to mark
+// resources for deletion.
+func newTaggingClients(ctx context.Context, org *resource.Organization, clients map[aws.ClientKey]aws.Client) (
+	map[aws.ClientKey]resource.TaggingAPI, error) {
+	if org != nil {
+		accountIDs := map[aws.ClientKey]string{}
+		for k, c := range clients {
+			accountIDs[k] = c.AccountID
+		}
+
+		return org.NewTaggingClients(ctx, accountIDs)
+	}
+
+	clientKeys := make([]aws.ClientKey, 0, len(clients))
+	for k := range clients {
+		clientKeys = append(clientKeys, k)
+	}
+
+	return resource.NewTaggingClients(ctx, clientKeys)
+}
+
+// apply deletes exactly the resources of a plan file, except the ones that are protected. If org is set,
+// resources are deleted in the member accounts of the organization.
+func apply(ctx context.Context, org *resource.Organization, protection resource.Protection, pathToPlan string,
+	planMaxAge, timeout time.Duration, outputType string, opts runOptions) int {
+	plan, err := resource.ReadPlan(pathToPlan)
+	if err != nil {
+		fmt.Fprint(os.Stderr, color.RedString("Error: failed to read plan: %s\n", err))
+		return opts.finish(exitCodeError)
+	}
+
+	err = plan.Validate(planMaxAge, providerVersion)
+	if err != nil {
+		fmt.Fprint(os.Stderr, color.RedString("Error: invalid plan: %s\n", err))
+		return opts.finish(exitCodeError)
 	}
 
-	doneDelete := make(chan bool, 1)
+	var providers map[aws.ClientKey]provider.TerraformProvider
+	if org != nil {
+		providers, err = org.NewProviderPool(ctx, plan.AccountIDs(), providerVersion, providerInstallDir, timeout)
+	} else {
+		providers, err = terraform.NewProviderPool(ctx, plan.ClientKeys(), providerVersion, providerInstallDir, timeout)
+	}
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return opts.finish(exitCodeAborted)
+		}
+
+		fmt.Fprint(os.Stderr, color.RedString("\nError: %s\n", err))
+
+		return opts.finish(exitCodeError)
+	}
+
+	defer func() {
+		for _, p := range providers {
+			_ = p.Close()
+		}
+	}()
+
+	resources, err := plan.TerraformResources(providers)
+	if err != nil {
+		fmt.Fprint(os.Stderr, color.RedString("Error: invalid plan: %s\n", err))
+		return opts.finish(exitCodeError)
+	}
+
+	internal.LogTitle(fmt.Sprintf("showing resources of plan created at %s", plan.CreatedAt))
+
+	resources, protected := protection.Apply(resources)
+
+	resource.PrintGrouped(resources, outputType)
+
+	for _, r := range protected {
+		log.WithField("id", r.ID).Warn(internal.Pad(r.Type) + "skipped (protected)")
+	}
+
+	opts.report.Add(resource.OutcomeProtected, protected...)
+
+	return runDelete(ctx, resources, opts)
+}
+
+func runDelete(ctx context.Context, resources []terraform.Resource, opts runOptions) int {
+	doneDelete := make(chan int, 1)
 	go func() {
-		delete(resources, force, dryRun, parallel, doneDelete)
+		delete(ctx, resources, opts, doneDelete)
 	}()
 	select {
 	case <-ctx.Done():
-		return 0
-	case <-doneDelete:
-	}
+		// the outcome of resources whose deletion is still in progress is unknown
+		opts.report.AddUnreported(resource.OutcomeSkipped, resources...)
 
-	return 0
+		return opts.finish(exitCodeAborted)
+	case exitCode := <-doneDelete:
+		return opts.finish(exitCode)
+	}
 }
 
-func delete(resources []terradozerRes.DestroyableResource, force bool, dryRun bool, parallel int, done chan bool) {
+// delete deletes the given resources and sends the exit code of the deletion to done when finished.
+func delete(ctx context.Context, resources []terraform.Resource, opts runOptions, done chan int) {
 	if len(resources) == 0 {
 		internal.LogTitle("no resources found to delete")
-		done <- true
+		done <- exitCodeNothingMatched
 		return
 	}
 
-	internal.LogTitle(fmt.Sprintf("total number of resources that would be deleted: %d", len(resources)))
+	logSummary("total number of resources that would be deleted: %d", resources)
 
-	if !dryRun {
-		if !force {
+	if opts.dryRun {
+		opts.report.Add(resource.OutcomeSkipped, resources...)
+
+		if exceeded := checkLimits(resources, opts); exceeded {
+			done <- exitCodeTooManyResources
+			return
+		}
+	} else {
+		// the limits are checked before the user is asked about each resource, since selecting
+		// fewer resources must not be the way to get around a filter that matches too many
+		if exceeded := checkLimits(resources, opts); exceeded {
+			done <- exitCodeTooManyResources
+			return
+		}
+
+		if opts.interactive {
+			var kept []terraform.Resource
+
+			resources, kept = selectResources(resources, opts.parents)
+			opts.report.Add(resource.OutcomeSkipped, kept...)
+
+			if len(resources) == 0 {
+				internal.LogTitle("no resources selected for deletion")
+				done <- exitCodeAborted
+				return
+			}
+
+			logSummary("total number of selected resources that would be deleted: %d", resources)
+
+			for _, r := range resources {
+				log.WithField("id", r.ID).Info(internal.Pad(r.Type))
+			}
+		}
+
+		if !opts.force {
 			if !internal.UserConfirmedDeletion(os.Stdin) {
-				done <- true
+				opts.report.Add(resource.OutcomeSkipped, resources...)
+				done <- exitCodeAborted
 				return
 			}
 		} else {
@@ -225,12 +897,192 @@ func delete(resources []terradozerRes.DestroyableResource, force bool, dryRun bo
 
 		internal.LogTitle("Starting to delete resources")
 
-		numDeletedResources := terradozerRes.DestroyResources(resources, parallel)
+		result := resource.DestroyResources(ctx, resource.Destroyable(resources), opts.parallel, opts.maxRounds)
+
+		if len(result.Failed) > 0 {
+			internal.LogTitle(fmt.Sprintf("failed to delete the following resources after %d round(s): %d",
+				result.Rounds, len(result.Failed)))
+
+			for _, err := range result.Failed {
+				logger := log.WithError(err).WithField("id", err.Resource.ID())
+
+				switch {
+				case err.IsTimeout():
+					logger = logger.WithField("reason", "timeout")
+				case err.IsDependencyError():
+					logger = logger.WithField("reason", "dependency")
+				}
+
+				logger.Warn(internal.Pad(err.Resource.Type()))
+			}
+		}
+
+		destroyed := map[terradozerRes.DestroyableResource]bool{}
+		for _, r := range result.Destroyed {
+			destroyed[r] = true
+		}
+
+		failed := map[terradozerRes.DestroyableResource]resource.DestroyError{}
+		for _, err := range result.Failed {
+			failed[err.Resource] = err
+		}
+
+		var destroyedRes []terraform.Resource
+		for _, r := range resources {
+			d, ok := resource.AsDestroyable(r)
+			if !ok {
+				opts.report.Add(resource.OutcomeSkipped, r)
+				continue
+			}
+
+			if destroyed[d] {
+				destroyedRes = append(destroyedRes, r)
+				opts.report.Add(resource.OutcomeDeleted, r)
+			} else if err, ok := failed[d]; ok {
+				if err.IsTimeout() {
+					opts.report.AddWithError(r, resource.OutcomeTimedOut, err.Err)
+				} else {
+					opts.report.AddWithError(r, resource.OutcomeFailed, err.Err)
+				}
+			} else {
+				opts.report.Add(resource.OutcomeSkipped, r)
+			}
+		}
+
+		logSummary("total number of deleted resources: %d", destroyedRes)
+
+		if len(result.Failed) > 0 {
+			done <- exitCodeDeleteFailed
+			return
+		}
+	}
+
+	done <- exitCodeOK
+}
+
+// validate prints the problems found in a filter file (without listing any resources).
+func validate(path, outputType string) int {
+	diagnostics, err := resource.ValidateFile(path)
+	if err != nil {
+		fmt.Fprint(os.Stderr, color.RedString("Error: failed to read filter: %s\n", err))
+		return exitCodeError
+	}
+
+	err = diagnostics.Print(outputType)
+	if err != nil {
+		fmt.Fprint(os.Stderr, color.RedString("Error: failed to print diagnostics: %s\n", err))
+		return exitCodeError
+	}
+
+	if diagnostics.HasErrors() {
+		return exitCodeError
+	}
+
+	if strings.ToLower(outputType) != "json" {
+		log.Infof("filter is valid: %s", path)
+	}
+
+	return exitCodeOK
+}
 
-		internal.LogTitle(fmt.Sprintf("total number of deleted resources: %d", numDeletedResources))
+// checkLimits returns true (and adds the resources to the report as skipped, unless it's a dry run)
+// if the matched resources exceed the maximum number of deletions.
+func checkLimits(resources []terraform.Resource, opts runOptions) bool {
+	err := opts.limits.Check(resources, opts.parents)
+	if err == nil {
+		return false
 	}
 
-	done <- true
+	fmt.Fprint(os.Stderr, color.RedString("Error: aborting without deleting anything: %s\n", err))
+
+	if !opts.dryRun {
+		opts.report.Add(resource.OutcomeSkipped, resources...)
+	}
+
+	return true
+}
+
+// selectResources lets the user select which of the given resources to delete. The user is only asked about
+// matched resources; resources of dependent types (e.g., policy attachments of IAM users) follow the answer
+// for the resource they belong to. It returns the resources to delete and the ones to keep.
+func selectResources(resources []terraform.Resource, parents resource.Parents) ([]terraform.Resource,
+	[]terraform.Resource) {
+	groups, _ := resource.GroupByAccountRegion(resources)
+
+	indexes := map[terradozerRes.UpdatableResource]int{}
+	for i, r := range resources {
+		if r.UpdatableResource != nil {
+			indexes[r.UpdatableResource] = i
+		}
+	}
+
+	// children maps the index of a resource to the indexes of the resources that belong to it
+	children := map[int][]int{}
+
+	var asked []int
+
+	for i, r := range resources {
+		if parent, ok := parents.Of(r); ok {
+			if j, ok := indexes[parent.UpdatableResource]; ok {
+				children[j] = append(children[j], i)
+				continue
+			}
+		}
+
+		asked = append(asked, i)
+	}
+
+	items := make([]internal.SelectItem, len(asked))
+	for k, i := range asked {
+		r := resources[i]
+
+		items[k] = internal.SelectItem{Group: r.Type, Name: r.ID}
+
+		if len(groups) > 1 {
+			items[k].Name = fmt.Sprintf("%s (%s)", r.ID,
+				resource.AccountRegion{Profile: r.Profile, AccountID: r.AccountID, Region: r.Region})
+		}
+
+		if n := len(children[i]); n > 0 {
+			items[k].Name += fmt.Sprintf(" [including %d dependent resource(s)]", n)
+		}
+	}
+
+	selected := map[int]bool{}
+	for _, k := range internal.UserSelected(os.Stdin, items, "Do you want to delete this resource?") {
+		selected[asked[k]] = true
+
+		for _, i := range children[asked[k]] {
+			selected[i] = true
+		}
+	}
+
+	var toDelete, toKeep []terraform.Resource
+
+	for i, r := range resources {
+		if selected[i] {
+			toDelete = append(toDelete, r)
+		} else {
+			toKeep = append(toKeep, r)
+		}
+	}
+
+	return toDelete, toKeep
+}
+
+// logSummary logs the total number of the given resources and, if they belong to
+// more than one account or region, the number of resources per account and region.
+func logSummary(title string, resources []terraform.Resource) {
+	internal.LogTitle(fmt.Sprintf(title, len(resources)))
+
+	groups, resByGroup := resource.GroupByAccountRegion(resources)
+	if len(groups) < 2 {
+		return
+	}
+
+	for _, g := range groups {
+		log.Infof("%s%d", internal.Pad(g.String()), len(resByGroup[g]))
+	}
 }
 
 func printHelp(fs *flag.FlagSet) {
@@ -245,5 +1097,30 @@ Delete AWS resources via a YAML filter.
 USAGE:
   $ awsweeper [flags] <filter.yml>
 
+  # decide for each matched resource whether to delete or keep it
+  $ awsweeper --interactive <filter.yml>
+
+  # delete resources in all enabled regions of multiple accounts
+  $ awsweeper --profiles dev,test --all-regions <filter.yml>
+
+  # tag resources for deletion and delete them with a later sweep run after a grace period
+  $ awsweeper --mark <filter.yml>
+  $ awsweeper --sweep --grace-period 168h <filter.yml>
+
+  # write the outcome of every matched resource to a JSON report (e.g. for CI)
+  $ awsweeper --force --report report.json <filter.yml>
+
+  # delete resources in the member accounts of an AWS Organization (via the central account's profile)
+  $ awsweeper --profile tooling --org-role OrganizationAccountAccessRole <filter.yml>
+
+  # check a filter file for problems (no AWS credentials needed; use --output json for editor integration)
+  $ awsweeper validate <filter.yml>
+
+  # write the resources that would be deleted to a plan file (for review)
+  $ awsweeper plan [flags] <filter.yml>
+
+  # delete exactly the resources of a plan file, without listing resources again
+  $ awsweeper apply [flags] <plan.json>
+
 FLAGS:
 `
@@ -0,0 +1,212 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/apex/log"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/jckuester/awstools-lib/terraform"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func init() {
+	RegisterExpander("aws_s3_bucket", Expander{
+		ChildType: "aws_s3_bucket_objects",
+		Expand:    expandBucketObjects,
+		Enabled: func(o Options) bool {
+			return o.EmptyBuckets
+		},
+	})
+}
+
+// S3API is the part of the S3 API used to empty buckets.
+type S3API interface {
+	ListObjectVersions(ctx context.Context, params *s3.ListObjectVersionsInput,
+		optFns ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error)
+	DeleteObjects(ctx context.Context, params *s3.DeleteObjectsInput,
+		optFns ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error)
+}
+
+// BucketContent is the content of an S3 bucket that is deleted when the bucket is emptied.
+type BucketContent struct {
+	// Versions is the number of object versions (for unversioned buckets, the number of objects).
+	Versions int
+	// DeleteMarkers is the number of delete markers of objects (only in versioned buckets).
+	DeleteMarkers int
+	// Size is the total size of all object versions in bytes.
+	Size int64
+}
+
+// Objects returns the number of object versions and delete markers.
+func (c BucketContent) Objects() int {
+	return c.Versions + c.DeleteMarkers
+}
+
+func (c BucketContent) String() string {
+	return fmt.Sprintf("%d objects (%d versions, %d delete markers), %s", c.Objects(), c.Versions, c.DeleteMarkers,
+		formatBytes(c.Size))
+}
+
+// formatBytes formats a number of bytes in binary units (e.g., 1.5 MiB).
+func formatBytes(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
+
+// ReadBucketContent counts the object versions and delete markers of a bucket and sums up their size.
+func ReadBucketContent(ctx context.Context, client S3API, bucket string) (BucketContent, error) {
+	var result BucketContent
+
+	err := listObjectVersions(ctx, client, bucket, func(page *s3.ListObjectVersionsOutput) error {
+		result.Versions += len(page.Versions)
+		result.DeleteMarkers += len(page.DeleteMarkers)
+
+		for _, v := range page.Versions {
+			result.Size += v.Size
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+// EmptyBucket deletes all object versions and delete markers of a bucket in batches (one batch per listed page,
+// i.e. at most 1000 objects). After each batch, progress is called with the number of objects deleted so far.
+// It returns the number of deleted objects.
+func EmptyBucket(ctx context.Context, client S3API, bucket string, progress func(deleted int)) (int, error) {
+	var deleted int
+
+	err := listObjectVersions(ctx, client, bucket, func(page *s3.ListObjectVersionsOutput) error {
+		var objects []types.ObjectIdentifier
+
+		for _, v := range page.Versions {
+			objects = append(objects, types.ObjectIdentifier{Key: v.Key, VersionId: v.VersionId})
+		}
+
+		for _, m := range page.DeleteMarkers {
+			objects = append(objects, types.ObjectIdentifier{Key: m.Key, VersionId: m.VersionId})
+		}
+
+		if len(objects) == 0 {
+			return nil
+		}
+
+		output, err := client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: &bucket,
+			Delete: &types.Delete{
+				Objects: objects,
+				Quiet:   true,
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(output.Errors) > 0 {
+			e := output.Errors[0]
+
+			return fmt.Errorf("failed to delete %d objects (e.g., key=%s: %s)",
+				len(output.Errors), stringValue(e.Key), stringValue(e.Message))
+		}
+
+		deleted += len(objects)
+
+		if progress != nil {
+			progress(deleted)
+		}
+
+		return nil
+	})
+
+	return deleted, err
+}
+
+// listObjectVersions calls fn for every page of object versions and delete markers of a bucket.
+func listObjectVersions(ctx context.Context, client S3API, bucket string,
+	fn func(page *s3.ListObjectVersionsOutput) error) error {
+	input := &s3.ListObjectVersionsInput{
+		Bucket: &bucket,
+	}
+
+	for {
+		page, err := client.ListObjectVersions(ctx, input)
+		if err != nil {
+			return err
+		}
+
+		err = fn(page)
+		if err != nil {
+			return err
+		}
+
+		if !page.IsTruncated {
+			return nil
+		}
+
+		input.KeyMarker = page.NextKeyMarker
+		input.VersionIdMarker = page.NextVersionIdMarker
+	}
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+
+	return *s
+}
+
+// expandBucketObjects returns the objects of a bucket, i.e. all object versions and delete markers of the bucket
+// as one resource (with ID <bucket>/*), which is deleted before the bucket. The objects are not read until they
+// are deleted.
+//
+// Note: the Terraform AWS Provider empties a bucket as well when it is destroyed (via force_destroy), but without
+// showing any progress, which can take very long for large buckets.
+func expandBucketObjects(ctx context.Context, clients ExpanderClients, bucket terraform.Resource) ([]Child, []error) {
+	name := bucket.ID
+	id := name + "/*"
+
+	// the state references the bucket, so that the objects are deleted before their bucket
+	state := cty.ObjectVal(map[string]cty.Value{
+		"id":     cty.StringVal(id),
+		"bucket": cty.StringVal(name),
+	})
+
+	return []Child{{
+		Type: "aws_s3_bucket_objects",
+		ID:   id,
+		Resource: NewAPIResource("aws_s3_bucket_objects", id, state, func(ctx context.Context) error {
+			return emptyBucketWithProgress(ctx, clients.S3, name)
+		}),
+	}}, nil
+}
+
+// emptyBucketWithProgress empties a bucket and logs the progress.
+func emptyBucketWithProgress(ctx context.Context, client S3API, bucket string) error {
+	logger := log.WithFields(log.Fields{
+		"type": "aws_s3_bucket_objects",
+		"id":   bucket + "/*",
+	})
+
+	deleted, err := EmptyBucket(ctx, client, bucket, func(deleted int) {
+		logger.Infof("deleted %d objects", deleted)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to empty bucket after deleting %d objects: %s", deleted, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package resource_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/jckuester/awstools-lib/terraform"
+	"github.com/jckuester/awsweeper/pkg/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 is a bucket with object versions and delete markers, which are listed in pages of pageSize entries.
+type fakeS3 struct {
+	versions      []types.ObjectVersion
+	deleteMarkers []types.DeleteMarkerEntry
+	pageSize      int
+	// deleted are the deleted objects per call of DeleteObjects
+	deleted [][]types.ObjectIdentifier
+	// failKey is a key that cannot be deleted
+	failKey string
+}
+
+func (f *fakeS3) ListObjectVersions(_ context.Context, params *s3.ListObjectVersionsInput,
+	_ ...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	start := 0
+	if params.KeyMarker != nil {
+		start, _ = strconv.Atoi(*params.KeyMarker)
+	}
+
+	end := start + f.pageSize
+
+	var output s3.ListObjectVersionsOutput
+
+	// the fake lists all versions first, followed by all delete markers
+	for i := start; i < end && i < len(f.versions)+len(f.deleteMarkers); i++ {
+		if i < len(f.versions) {
+			output.Versions = append(output.Versions, f.versions[i])
+		} else {
+			output.DeleteMarkers = append(output.DeleteMarkers, f.deleteMarkers[i-len(f.versions)])
+		}
+	}
+
+	if end < len(f.versions)+len(f.deleteMarkers) {
+		output.IsTruncated = true
+		output.NextKeyMarker = aws.String(strconv.Itoa(end))
+		output.NextVersionIdMarker = aws.String("v")
+	}
+
+	return &output, nil
+}
+
+func (f *fakeS3) DeleteObjects(_ context.Context, params *s3.DeleteObjectsInput,
+	_ ...func(*s3.Options)) (*s3.DeleteObjectsOutput, error) {
+	var output s3.DeleteObjectsOutput
+
+	for _, o := range params.Delete.Objects {
+		if *o.Key == f.failKey {
+			output.Errors = append(output.Errors, types.Error{Key: o.Key, Message: aws.String("Access Denied")})
+		}
+	}
+
+	f.deleted = append(f.deleted, params.Delete.Objects)
+
+	return &output, nil
+}
+
+func newFakeS3(versions, deleteMarkers int) *fakeS3 {
+	f := &fakeS3{pageSize: 2}
+
+	for i := 0; i < versions; i++ {
+		f.versions = append(f.versions, types.ObjectVersion{
+			Key:       aws.String(fmt.Sprintf("object-%d", i/2)),
+			VersionId: aws.String(fmt.Sprintf("version-%d", i)),
+			Size:      512,
+		})
+	}
+
+	for i := 0; i < deleteMarkers; i++ {
+		f.deleteMarkers = append(f.deleteMarkers, types.DeleteMarkerEntry{
+			Key:       aws.String(fmt.Sprintf("deleted-%d", i)),
+			VersionId: aws.String(fmt.Sprintf("marker-%d", i)),
+		})
+	}
+
+	return f
+}
+
+func TestReadBucketContent(t *testing.T) {
+	client := newFakeS3(4, 1)
+
+	content, err := resource.ReadBucketContent(context.Background(), client, "my-bucket")
+	require.NoError(t, err)
+
+	assert.Equal(t, resource.BucketContent{Versions: 4, DeleteMarkers: 1, Size: 2048}, content)
+	assert.Equal(t, "5 objects (4 versions, 1 delete markers), 2.0 KiB", content.String())
+	assert.Empty(t, client.deleted)
+}
+
+func TestEmptyBucket(t *testing.T) {
+	client := newFakeS3(4, 1)
+
+	var progress []int
+
+	deleted, err := resource.EmptyBucket(context.Background(), client, "my-bucket", func(deleted int) {
+		progress = append(progress, deleted)
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 5, deleted)
+	assert.Equal(t, []int{2, 4, 5}, progress)
+
+	require.Len(t, client.deleted, 3)
+	assert.Equal(t, []types.ObjectIdentifier{
+		{Key: aws.String("object-0"), VersionId: aws.String("version-0")},
+		{Key: aws.String("object-0"), VersionId: aws.String("version-1")},
+	}, client.deleted[0])
+	assert.Equal(t, []types.ObjectIdentifier{
+		{Key: aws.String("deleted-0"), VersionId: aws.String("marker-0")},
+	}, client.deleted[2])
+}
+
+func TestEmptyBucket_Empty(t *testing.T) {
+	client := newFakeS3(0, 0)
+
+	deleted, err := resource.EmptyBucket(context.Background(), client, "my-bucket", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, 0, deleted)
+	assert.Empty(t, client.deleted)
+}
+
+func TestEmptyBucket_Errors(t *testing.T) {
+	client := newFakeS3(4, 0)
+	client.failKey = "object-1"
+
+	deleted, err := resource.EmptyBucket(context.Background(), client, "my-bucket", nil)
+	assert.EqualError(t, err, "failed to delete 2 objects (e.g., key=object-1: Access Denied)")
+	assert.Equal(t, 2, deleted)
+
+	_, err = resource.EmptyBucket(context.Background(), failingS3{}, "my-bucket", nil)
+	assert.EqualError(t, err, "AccessDenied")
+}
+
+type failingS3 struct {
+	*fakeS3
+}
+
+func (failingS3) ListObjectVersions(context.Context, *s3.ListObjectVersionsInput,
+	...func(*s3.Options)) (*s3.ListObjectVersionsOutput, error) {
+	return nil, errors.New("AccessDenied")
+}
+
+func TestExpanders_S3Bucket(t *testing.T) {
+	client := newFakeS3(3, 0)
+
+	expanders := resource.Expanders("aws_s3_bucket")
+	require.Len(t, expanders, 1)
+	assert.Equal(t, "aws_s3_bucket_objects", expanders[0].ChildType)
+
+	// only enabled by the option empty_buckets
+	assert.False(t, expanders[0].Enabled(resource.Options{}))
+	assert.True(t, expanders[0].Enabled(resource.Options{EmptyBuckets: true}))
+
+	children, errs := expanders[0].Expand(context.Background(), resource.ExpanderClients{S3: client},
+		terraform.Resource{Type: "aws_s3_bucket", ID: "my-bucket"})
+	require.Empty(t, errs)
+	require.Len(t, children, 1)
+	assert.Equal(t, "my-bucket/*", children[0].ID)
+
+	// the objects are not read before they are deleted
+	assert.Empty(t, client.deleted)
+
+	require.NoError(t, children[0].Resource.Destroy())
+	assert.Len(t, client.deleted, 2)
+}
@@ -0,0 +1,96 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/jckuester/awstools-lib/aws"
+)
+
+// defaultRegion is used to discover the enabled regions of an account if a profile has no region configured.
+const defaultRegion = "us-east-1"
+
+// NewClientPool creates an AWS client for each permutation of the given profiles and regions.
+// If allRegions is set, a client is created for each region that is enabled in the account of a profile instead.
+func NewClientPool(ctx context.Context, profiles []string, regions []string,
+	allRegions bool) (map[aws.ClientKey]aws.Client, error) {
+	if !allRegions {
+		return aws.NewClientPool(ctx, profiles, regions)
+	}
+
+	if len(regions) > 0 {
+		return nil, fmt.Errorf("regions cannot be set when sweeping all regions")
+	}
+
+	if len(profiles) == 0 {
+		// use the default credential provider chain
+		profiles = []string{""}
+	}
+
+	result := map[aws.ClientKey]aws.Client{}
+
+	for _, profile := range profiles {
+		defaultClients, err := aws.NewClientPool(ctx, profileSlice(profile), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var enabledRegions []string
+		for _, client := range defaultClients {
+			enabledRegions, err = EnabledRegions(ctx, client)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		clients, err := aws.NewClientPool(ctx, profileSlice(profile), enabledRegions)
+		if err != nil {
+			return nil, err
+		}
+
+		for k, v := range clients {
+			result[k] = v
+		}
+	}
+
+	return result, nil
+}
+
+// EnabledRegions returns the regions that are enabled for the account of the given client.
+func EnabledRegions(ctx context.Context, client aws.Client) ([]string, error) {
+	var optFns []func(*ec2.Options)
+	if client.Region == "" {
+		optFns = append(optFns, func(o *ec2.Options) {
+			o.Region = defaultRegion
+		})
+	}
+
+	output, err := client.Ec2conn.DescribeRegions(ctx, &ec2.DescribeRegionsInput{}, optFns...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover enabled regions (profile=%s): %s", client.Profile, err)
+	}
+
+	var result []string
+
+	for _, r := range output.Regions {
+		if r.RegionName != nil {
+			result = append(result, *r.RegionName)
+		}
+	}
+
+	sort.Strings(result)
+
+	return result, nil
+}
+
+// profileSlice returns the given profile as slice, where an empty profile
+// means that the default credential provider chain is used.
+func profileSlice(profile string) []string {
+	if profile == "" {
+		return nil
+	}
+
+	return []string{profile}
+}
@@ -0,0 +1,251 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/jckuester/awstools-lib/aws"
+	"github.com/jckuester/awstools-lib/terraform"
+	"github.com/jckuester/terradozer/pkg/provider"
+	terradozerRes "github.com/jckuester/terradozer/pkg/resource"
+)
+
+// StackNameTag and StackIDTag are the tags that CloudFormation adds to all resources it creates as part of a stack.
+//
+// Note: the Terraform AWS Provider drops tags with prefix "aws:" from the state, so the tags are either taken from
+// the tags that are listed via the AWS API (if any) or set for the physical resources of stacks (see SetStackTags).
+const (
+	StackNameTag = "aws:cloudformation:stack-name"
+	// StackIDTag is the ARN of the stack.
+	StackIDTag = "aws:cloudformation:stack-id"
+)
+
+// Stack identifies a CloudFormation stack.
+type Stack struct {
+	Name string
+	// ID is the ARN of the stack.
+	ID string
+}
+
+// CloudFormationAPI is the part of the CloudFormation API used to find the resources owned by stacks.
+type CloudFormationAPI interface {
+	cloudformation.DescribeStacksAPIClient
+	cloudformation.ListStackResourcesAPIClient
+}
+
+// StackOwners returns the owning stacks by the physical IDs of all resources of the stacks in an account and region.
+func StackOwners(ctx context.Context, api CloudFormationAPI) (map[string]Stack, error) {
+	result := map[string]Stack{}
+
+	pg := cloudformation.NewDescribeStacksPaginator(api, &cloudformation.DescribeStacksInput{})
+
+	for pg.HasMorePages() {
+		page, err := pg.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list stacks: %s", err)
+		}
+
+		for _, stack := range page.Stacks {
+			resourcesPg := cloudformation.NewListStackResourcesPaginator(api, &cloudformation.ListStackResourcesInput{
+				StackName: stack.StackId,
+			})
+
+			for resourcesPg.HasMorePages() {
+				resourcesPage, err := resourcesPg.NextPage(ctx)
+				if err != nil {
+					return nil, fmt.Errorf("failed to list resources of stack %s: %s", *stack.StackName, err)
+				}
+
+				for _, r := range resourcesPage.StackResourceSummaries {
+					if r.PhysicalResourceId != nil && *r.PhysicalResourceId != "" {
+						result[*r.PhysicalResourceId] = Stack{Name: *stack.StackName, ID: *stack.StackId}
+					}
+				}
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// SetStackTags sets the StackNameTag and StackIDTag of the given resources whose ID or ARN is the physical ID
+// of a resource of a stack (see StackOwners).
+func SetStackTags(res []terraform.Resource, owners map[string]Stack) {
+	for i, r := range res {
+		stack, ok := owners[r.ID]
+		if !ok {
+			arn, err := GetAttribute(&res[i], "arn")
+			if err != nil {
+				continue
+			}
+
+			stack, ok = owners[arn]
+			if !ok {
+				continue
+			}
+		}
+
+		tags := make(map[string]string, len(r.Tags)+2)
+		for k, v := range r.Tags {
+			tags[k] = v
+		}
+		tags[StackNameTag] = stack.Name
+		tags[StackIDTag] = stack.ID
+
+		res[i].Tags = tags
+	}
+}
+
+// StackName returns the name of the CloudFormation stack that owns a resource. It returns false
+// if the resource isn't owned by a stack.
+func StackName(r terraform.Resource) (string, bool) {
+	name, ok := r.Tags[StackNameTag]
+	if !ok || name == "" {
+		return "", false
+	}
+
+	return name, true
+}
+
+// StackOwned returns the given resources that are owned by a CloudFormation stack and would match the filter if
+// they weren't owned by the stack, i.e., the ones that are skipped, since no matching filter entry sets
+// include_cloudformation. Deleting such resources individually leaves the stack broken, so the stack itself should be
+// deleted instead (via a filter for aws_cloudformation_stack).
+//
+// Note: the tags of the resources must have been read already (e.g., by applying the filter).
+func (f Filter) StackOwned(res []terraform.Resource) []terraform.Resource {
+	return f.stackOwned(res, nil)
+}
+
+// stackOwned returns the given resources that are skipped, since they are owned by a CloudFormation stack
+// (see StackOwned), where accounts are matched by the given names as well.
+func (f Filter) stackOwned(res []terraform.Resource, names AccountNames) []terraform.Resource {
+	var result []terraform.Resource
+
+	for _, r := range res {
+		if _, ok := StackName(r); !ok {
+			continue
+		}
+
+		if !f.match(r, false, names) && f.match(r, true, names) {
+			result = append(result, r)
+		}
+	}
+
+	return result
+}
+
+// OwningStack is a CloudFormation stack that owns resources that are skipped.
+type OwningStack struct {
+	AccountRegion
+	Name string
+	// ID is the ARN of the stack (empty if the StackIDTag of its resources is unknown).
+	ID string
+	// Resources are the skipped resources owned by the stack.
+	Resources []terraform.Resource
+	// Selected is true if the stack itself matches the filter, i.e. it is deleted instead of its resources.
+	Selected bool
+}
+
+// OwningStacks groups the given resources owned by CloudFormation stacks by their stack (in the order they first
+// appear in) and checks for each stack whether it is one of the selected resources.
+func OwningStacks(stackOwned, selected []terraform.Resource) []OwningStack {
+	type stackKey struct {
+		AccountRegion
+		name string
+	}
+
+	selectedStacks := map[stackKey]bool{}
+
+	for _, r := range selected {
+		if r.Type != "aws_cloudformation_stack" {
+			continue
+		}
+
+		selectedStacks[stackKey{accountRegion(r), stackResourceName(r)}] = true
+	}
+
+	var result []OwningStack
+	indexes := map[stackKey]int{}
+
+	for _, r := range stackOwned {
+		name, ok := StackName(r)
+		if !ok {
+			continue
+		}
+
+		key := stackKey{accountRegion(r), name}
+
+		i, ok := indexes[key]
+		if !ok {
+			i = len(result)
+			indexes[key] = i
+
+			result = append(result, OwningStack{
+				AccountRegion: key.AccountRegion,
+				Name:          name,
+				Selected:      selectedStacks[key],
+			})
+		}
+
+		if result[i].ID == "" {
+			result[i].ID = r.Tags[StackIDTag]
+		}
+
+		result[i].Resources = append(result[i].Resources, r)
+	}
+
+	return result
+}
+
+// Resource returns the stack as aws_cloudformation_stack resource (with its Terraform state read via the provider
+// of its account and region), so that it can be deleted instead of its resources. Like a listed stack,
+// its ID is the ARN of the stack.
+func (s OwningStack) Resource(providers map[aws.ClientKey]provider.TerraformProvider) (terraform.Resource, error) {
+	if s.ID == "" {
+		return terraform.Resource{}, fmt.Errorf("unknown ARN of stack: %s", s.Name)
+	}
+
+	p, ok := providers[aws.ClientKey{Profile: s.Profile, Region: s.Region}]
+	if !ok {
+		return terraform.Resource{}, fmt.Errorf("no provider found for %s", s.AccountRegion)
+	}
+
+	r := terraform.Resource{
+		Type:      "aws_cloudformation_stack",
+		ID:        s.ID,
+		Profile:   s.Profile,
+		AccountID: s.AccountID,
+		Region:    s.Region,
+	}
+	r.UpdatableResource = terradozerRes.New(r.Type, r.ID, nil, &p)
+
+	err := r.UpdateState()
+	if err != nil {
+		return terraform.Resource{}, err
+	}
+
+	return r, nil
+}
+
+func accountRegion(r terraform.Resource) AccountRegion {
+	return AccountRegion{Profile: r.Profile, AccountID: r.AccountID, Region: r.Region}
+}
+
+// stackResourceName returns the name of an aws_cloudformation_stack resource, whose ID is the ARN of the stack
+// (arn:aws:cloudformation:<region>:<account>:stack/<name>/<uuid>).
+func stackResourceName(r terraform.Resource) string {
+	name, err := GetAttribute(&r, "name")
+	if err == nil {
+		return name
+	}
+
+	parts := strings.Split(r.ID, "/")
+	if len(parts) == 3 && strings.HasSuffix(parts[0], ":stack") {
+		return parts[1]
+	}
+
+	return r.ID
+}
@@ -0,0 +1,125 @@
+package resource_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/jckuester/awstools-lib/terraform"
+	"github.com/jckuester/awsweeper/pkg/resource"
+	terradozerRes "github.com/jckuester/terradozer/pkg/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// fakeCloudFormation has stacks with the physical IDs of their resources.
+type fakeCloudFormation struct {
+	stacks map[string][]string
+	err    error
+}
+
+func (f *fakeCloudFormation) DescribeStacks(_ context.Context, _ *cloudformation.DescribeStacksInput,
+	_ ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	var output cloudformation.DescribeStacksOutput
+
+	for name := range f.stacks {
+		output.Stacks = append(output.Stacks, types.Stack{
+			StackName: aws.String(name),
+			StackId:   aws.String("arn:aws:cloudformation:us-west-2:111111111111:stack/" + name + "/uuid"),
+		})
+	}
+
+	return &output, nil
+}
+
+func (f *fakeCloudFormation) ListStackResources(_ context.Context, params *cloudformation.ListStackResourcesInput,
+	_ ...func(*cloudformation.Options)) (*cloudformation.ListStackResourcesOutput, error) {
+	var output cloudformation.ListStackResourcesOutput
+
+	for name, physicalIDs := range f.stacks {
+		if *params.StackName != "arn:aws:cloudformation:us-west-2:111111111111:stack/"+name+"/uuid" {
+			continue
+		}
+
+		for _, id := range physicalIDs {
+			output.StackResourceSummaries = append(output.StackResourceSummaries, types.StackResourceSummary{
+				PhysicalResourceId: aws.String(id),
+			})
+		}
+	}
+
+	return &output, nil
+}
+
+func stack(name string) resource.Stack {
+	return resource.Stack{Name: name, ID: "arn:aws:cloudformation:us-west-2:111111111111:stack/" + name + "/uuid"}
+}
+
+func TestStackOwners(t *testing.T) {
+	api := &fakeCloudFormation{stacks: map[string][]string{
+		"web":     {"i-1", "arn:aws:sns:us-west-2:111111111111:alerts"},
+		"network": {"vpc-1", ""},
+	}}
+
+	owners, err := resource.StackOwners(context.Background(), api)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]resource.Stack{
+		"i-1": stack("web"),
+		"arn:aws:sns:us-west-2:111111111111:alerts": stack("web"),
+		"vpc-1": stack("network"),
+	}, owners)
+
+	_, err = resource.StackOwners(context.Background(), &fakeCloudFormation{err: errors.New("AccessDenied")})
+	assert.EqualError(t, err, "failed to list stacks: AccessDenied")
+}
+
+func TestSetStackTags_Apply(t *testing.T) {
+	newResource := func(rType, id string, attrs map[string]cty.Value) terraform.Resource {
+		attrs["id"] = cty.StringVal(id)
+		// like the Terraform AWS Provider, the state doesn't contain tags with prefix aws:
+		attrs["tags"] = cty.MapVal(map[string]cty.Value{"env": cty.StringVal("dev")})
+		state := cty.ObjectVal(attrs)
+
+		return terraform.Resource{
+			Type:              rType,
+			ID:                id,
+			UpdatableResource: terradozerRes.NewWithState(rType, id, nil, &state),
+		}
+	}
+
+	res := []terraform.Resource{
+		newResource("aws_instance", "i-1", map[string]cty.Value{}),
+		newResource("aws_instance", "i-2", map[string]cty.Value{}),
+		newResource("aws_sns_topic", "alerts", map[string]cty.Value{
+			"arn": cty.StringVal("arn:aws:sns:us-west-2:111111111111:alerts"),
+		}),
+	}
+
+	resource.SetStackTags(res, map[string]resource.Stack{
+		"i-1": stack("web"),
+		"arn:aws:sns:us-west-2:111111111111:alerts": stack("web"),
+	})
+
+	f := resource.Filter{
+		"aws_instance":  {{Tags: map[string]resource.StringFilter{"env": {Pattern: "^dev$"}}}},
+		"aws_sns_topic": {},
+	}
+
+	assert.Equal(t, []string{"i-2"}, ids(f.Apply(res)))
+	assert.Equal(t, []string{"i-1", "alerts"}, ids(f.StackOwned(res)))
+
+	name, ok := resource.StackName(res[0])
+	require.True(t, ok)
+	assert.Equal(t, "web", name)
+	assert.Equal(t, stack("web").ID, res[0].Tags[resource.StackIDTag])
+	assert.Equal(t, "dev", res[0].Tags["env"])
+}
@@ -0,0 +1,191 @@
+package resource
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config represents the content of a yaml file, which consists of filters for resource types
+// (top-level keys with prefix "aws_") and options (all other top-level keys).
+type Config struct {
+	Filter  Filter `yaml:",inline"`
+	Options `yaml:",inline"`
+	// MarkedBefore, if set, selects only resources that have been marked for deletion before this time.
+	MarkedBefore *time.Time `yaml:"-"`
+	// Managed are the resources of the Terraform states in ExcludeTerraformState, which are never selected.
+	Managed ManagedResources `yaml:"-"`
+	// AccountNames are the names of the member accounts of an organization, which account criteria match as well.
+	AccountNames AccountNames `yaml:"-"`
+}
+
+// Options are the top-level settings in the yaml file that are not filters for a resource type.
+type Options struct {
+	// Protect lists resources that must never be deleted, even if a filter matches them.
+	Protect Protection `yaml:",omitempty"`
+	// Strict makes filter criteria that cannot be evaluated for a resource type or a resource an error
+	// (instead of the filter entry not matching).
+	Strict bool `yaml:",omitempty"`
+	// MaxDeletions is the maximum number of resources per type that may be deleted in a run.
+	MaxDeletions map[string]int `yaml:"max_deletions,omitempty"`
+	// ExcludeTerraformState are Terraform state files (or directories of them) whose resources are never selected.
+	// Relative paths are relative to the directory of the yaml file.
+	ExcludeTerraformState Paths `yaml:"exclude_terraform_state,omitempty"`
+	// EmptyBuckets deletes all objects, object versions and delete markers of S3 buckets in batches (with progress
+	// output) before the buckets are deleted, as resources of type aws_s3_bucket_objects.
+	EmptyBuckets bool `yaml:"empty_buckets,omitempty"`
+}
+
+// NewConfig creates a config defined via a given path to a yaml file.
+// The Terraform states of resources to exclude are read as well.
+func NewConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cfg.ExcludeTerraformState) > 0 {
+		var statePaths []string
+		for _, p := range cfg.ExcludeTerraformState {
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(filepath.Dir(path), p)
+			}
+
+			statePaths = append(statePaths, p)
+		}
+
+		cfg.Managed, err = ReadTerraformStates(statePaths...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return cfg, nil
+}
+
+// ParseConfig parses the content of a yaml file into a config, where unknown or duplicate keys are an error.
+// All regexes are compiled while parsing; invalid ones are reported together with their line numbers.
+func ParseConfig(data []byte) (*Config, error) {
+	var doc yaml.Node
+
+	err := yaml.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkTopLevelKeys(&doc)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	err = dec.Decode(cfg)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// checkTopLevelKeys checks that all top-level keys of a yaml document are unique and either
+// a resource type (prefix "aws_") or an option.
+//
+// Note: this can't be left to the decoder, since it decodes all keys that are not an option into the filter.
+func checkTopLevelKeys(doc *yaml.Node) error {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+
+	options := optionKeys()
+
+	var errs []string
+	keyLines := map[string]int{}
+
+	content := doc.Content[0].Content
+	for i := 0; i < len(content); i += 2 {
+		key := content[i]
+
+		if line, ok := keyLines[key.Value]; ok {
+			errs = append(errs, fmt.Sprintf("line %d: mapping key %q already defined at line %d",
+				key.Line, key.Value, line))
+			continue
+		}
+
+		keyLines[key.Value] = key.Line
+
+		if !strings.HasPrefix(key.Value, "aws_") && !options[key.Value] {
+			errs = append(errs, fmt.Sprintf("line %d: field %s not found in type %T", key.Line, key.Value, Options{}))
+		}
+	}
+
+	if len(errs) > 0 {
+		return &yaml.TypeError{Errors: errs}
+	}
+
+	return nil
+}
+
+// optionKeys returns the yaml keys of all options.
+func optionKeys() map[string]bool {
+	result := map[string]bool{}
+
+	t := reflect.TypeOf(Options{})
+	for i := 0; i < t.NumField(); i++ {
+		key := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if key == "" {
+			key = strings.ToLower(t.Field(i).Name)
+		}
+
+		result[key] = true
+	}
+
+	return result
+}
+
+// Validate checks if the filters and the options in the config are valid.
+// In strict mode, filter entries with criteria that the resource type doesn't support are an error.
+func (c Config) Validate() error {
+	err := c.Filter.Validate()
+	if err != nil {
+		return err
+	}
+
+	if unsupported := c.Filter.Unsupported(); c.Strict && len(unsupported) > 0 {
+		return unsupported[0]
+	}
+
+	maxDeletionTypes := make([]string, 0, len(c.MaxDeletions))
+	for rType := range c.MaxDeletions {
+		maxDeletionTypes = append(maxDeletionTypes, rType)
+	}
+	sort.Strings(maxDeletionTypes)
+
+	for _, rType := range maxDeletionTypes {
+		if _, ok := c.Filter[rType]; !ok {
+			return fmt.Errorf("max_deletions of %s: resource type is not part of the filter", rType)
+		}
+
+		if c.MaxDeletions[rType] < 1 {
+			return fmt.Errorf("max_deletions of %s must be greater than 0", rType)
+		}
+	}
+
+	return c.Protect.Validate()
+}
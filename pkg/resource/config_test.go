@@ -0,0 +1,95 @@
+package resource_test
+
+import (
+	"testing"
+
+	"github.com/jckuester/awsweeper/pkg/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfig(t *testing.T) {
+	input := []byte(`aws_instance:
+  - id: ^foo
+protect:
+  - type: aws_s3_bucket
+    id: ^terraform-state$
+  - arn: ^arn:aws:iam::123456789012:role/ci$
+aws_vpc:`)
+
+	cfg, err := resource.ParseConfig(input)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"aws_instance", "aws_vpc"}, cfg.Filter.Types())
+	require.Len(t, cfg.Filter["aws_instance"], 1)
+	assert.Equal(t, &resource.StringFilter{Pattern: "^foo"}, cfg.Filter["aws_instance"][0].ID)
+
+	assert.Equal(t, resource.Protection{
+		{Type: "aws_s3_bucket", ID: &resource.StringFilter{Pattern: "^terraform-state$"}},
+		{ARN: &resource.StringFilter{Pattern: "^arn:aws:iam::123456789012:role/ci$"}},
+	}, cfg.Protect)
+}
+
+func TestConfig_Validate_Strict(t *testing.T) {
+	cfg, err := resource.ParseConfig([]byte("aws_vpc:\n  - created:\n      before: 7d"))
+	require.NoError(t, err)
+	require.NoError(t, cfg.Validate())
+
+	cfg, err = resource.ParseConfig([]byte("strict: true\naws_vpc:\n  - created:\n      before: 7d"))
+	require.NoError(t, err)
+	assert.EqualError(t, cfg.Validate(),
+		"filter entry 1 of aws_vpc never matches: aws_vpc cannot be filtered by created")
+}
+
+func TestConfig_Validate_MaxDeletions(t *testing.T) {
+	cfg, err := resource.ParseConfig([]byte("max_deletions:\n  aws_instance: 10\naws_instance:"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"aws_instance": 10}, cfg.MaxDeletions)
+	require.NoError(t, cfg.Validate())
+
+	cfg, err = resource.ParseConfig([]byte("max_deletions:\n  aws_vpc: 10\naws_instance:"))
+	require.NoError(t, err)
+	assert.EqualError(t, cfg.Validate(), "max_deletions of aws_vpc: resource type is not part of the filter")
+
+	cfg, err = resource.ParseConfig([]byte("max_deletions:\n  aws_instance: 0\naws_instance:"))
+	require.NoError(t, err)
+	assert.EqualError(t, cfg.Validate(), "max_deletions of aws_instance must be greater than 0")
+}
+
+func TestParseConfig_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{
+			name:    "unknown option",
+			input:   "foo: bar",
+			wantErr: "yaml: unmarshal errors:\n  line 1: field foo not found in type resource.Options",
+		},
+		{
+			name:    "unknown field in type filter",
+			input:   "aws_instance:\n  - foo: bar",
+			wantErr: "yaml: unmarshal errors:\n  line 2: field foo not found in type resource.TypeFilter",
+		},
+		{
+			name:    "duplicate resource type",
+			input:   "aws_instance:\naws_instance:",
+			wantErr: "yaml: unmarshal errors:\n  line 2: mapping key \"aws_instance\" already defined at line 1",
+		},
+		{
+			name:  "invalid regexes",
+			input: "aws_instance:\n  - id: ^foo(\n    tags:\n      env: NOT([a-)\n    not:\n      account: '*'",
+			wantErr: "yaml: unmarshal errors:\n" +
+				"  line 2: invalid regex \"^foo(\": error parsing regexp: missing closing ): `^foo(`\n" +
+				"  line 4: invalid regex \"NOT([a-)\": error parsing regexp: missing closing ]: `[a-`\n" +
+				"  line 6: invalid regex \"*\": error parsing regexp: missing argument to repetition operator: `*`",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := resource.ParseConfig([]byte(tt.input))
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package resource
+
+// TypesWithCreationDate is a list of all resource types that have a creation date, i.e., whose creation date
+// is set when listing them via awsls (see also the table of supported resource types in the README).
+var TypesWithCreationDate = []string{
+	"aws_ami",
+	"aws_athena_workgroup",
+	"aws_autoscaling_group",
+	"aws_backup_plan",
+	"aws_backup_vault",
+	"aws_cloudformation_stack",
+	"aws_cloudwatch_event_archive",
+	"aws_cloudwatch_log_destination",
+	"aws_cloudwatch_log_group",
+	"aws_codeartifact_domain",
+	"aws_config_configuration_aggregator",
+	"aws_db_instance",
+	"aws_db_snapshot",
+	"aws_ebs_snapshot",
+	"aws_ebs_volume",
+	"aws_ec2_capacity_reservation",
+	"aws_ec2_client_vpn_endpoint",
+	"aws_ec2_fleet",
+	"aws_ec2_transit_gateway",
+	"aws_ec2_transit_gateway_peering_attachment",
+	"aws_ec2_transit_gateway_route_table",
+	"aws_ec2_transit_gateway_vpc_attachment",
+	"aws_efs_file_system",
+	"aws_elb",
+	"aws_fsx_lustre_file_system",
+	"aws_fsx_windows_file_system",
+	"aws_gamelift_alias",
+	"aws_gamelift_build",
+	"aws_globalaccelerator_accelerator",
+	"aws_glue_crawler",
+	"aws_glue_registry",
+	"aws_glue_schema",
+	"aws_iam_access_key",
+	"aws_iam_group",
+	"aws_iam_instance_profile",
+	"aws_iam_policy",
+	"aws_iam_role",
+	"aws_iam_service_linked_role",
+	"aws_iam_user",
+	"aws_instance",
+	"aws_iot_certificate",
+	"aws_launch_configuration",
+	"aws_launch_template",
+	"aws_lb",
+	"aws_media_store_container",
+	"aws_msk_cluster",
+	"aws_msk_configuration",
+	"aws_nat_gateway",
+	"aws_route53_resolver_endpoint",
+	"aws_route53_resolver_query_log_config",
+	"aws_route53_resolver_query_log_config_association",
+	"aws_route53_resolver_rule",
+	"aws_s3_bucket",
+	"aws_s3outposts_endpoint",
+	"aws_sagemaker_app_image_config",
+	"aws_sagemaker_code_repository",
+	"aws_sagemaker_endpoint",
+	"aws_sagemaker_feature_group",
+	"aws_sagemaker_model",
+	"aws_sagemaker_model_package_group",
+	"aws_service_discovery_service",
+	"aws_servicecatalog_portfolio",
+	"aws_sfn_activity",
+	"aws_sfn_state_machine",
+	"aws_spot_fleet_request",
+	"aws_spot_instance_request",
+	"aws_timestreamwrite_database",
+	"aws_vpc_endpoint",
+	"aws_worklink_fleet",
+}
+
+// SupportsCreationDate returns true if the given resource type has a creation date.
+func SupportsCreationDate(s string) bool {
+	for _, t := range TypesWithCreationDate {
+		if t == s {
+			return true
+		}
+	}
+
+	return false
+}
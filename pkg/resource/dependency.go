@@ -1,9 +1,21 @@
 package resource
 
+import (
+	"sort"
+	"strings"
+
+	terradozerRes "github.com/jckuester/terradozer/pkg/resource"
+	"github.com/zclconf/go-cty/cty"
+)
+
 var (
 	// DependencyOrder is the order in which resource types should be deleted,
 	// since dependent resources need to be deleted before their dependencies
-	// (e.g. aws_subnet before aws_vpc)
+	// (e.g. aws_subnet before aws_vpc).
+	//
+	// The order is only a fallback: resources are deleted in the order of their dependency graph (see Graph),
+	// which is built from the references between their Terraform states. DependencyOrder is used to order
+	// resource types for listing and to break cycles in the graph.
 	DependencyOrder = map[string]int{
 		"aws_lambda_function":      10100,
 		"aws_ecs_cluster":          10000,
@@ -41,3 +53,235 @@ var (
 		"aws_cloudtrail":           8800,
 	}
 )
+
+// minIdentifierLength is the minimum length of a value in a Terraform state to be considered as a reference
+// to another resource. Shorter values (e.g., "tcp" or "80") would create edges between unrelated resources.
+const minIdentifierLength = 4
+
+// Graph is a dependency graph of resources. A resource depends on another resource if any attribute
+// of its Terraform state references the other resource's ID or ARN (e.g. the vpc_id of an aws_subnet).
+//
+// IDs only reference resources in the same account and region, since IDs (e.g., of IAM roles or S3 buckets,
+// which are names) are not unique across accounts and regions. ARNs reference resources anywhere.
+type Graph struct {
+	resources []terradozerRes.DestroyableResource
+	// references maps the index of a resource to the indexes of the resources it references.
+	references map[int][]int
+	// numDependents is the number of resources referencing the resource with the given index.
+	numDependents map[int]int
+}
+
+// NewGraph builds the dependency graph of the given resources.
+//
+// Resources without a Terraform state can't reference other resources, but can be referenced by them.
+func NewGraph(resources []terradozerRes.DestroyableResource) *Graph {
+	g := &Graph{
+		resources:     resources,
+		references:    map[int][]int{},
+		numDependents: map[int]int{},
+	}
+
+	identifiers := map[string][]int{}
+
+	for i, r := range resources {
+		for _, id := range selfIdentifiers(r) {
+			identifiers[id] = append(identifiers[id], i)
+		}
+	}
+
+	for i, r := range resources {
+		state := stateOf(r)
+		if state == nil {
+			continue
+		}
+
+		self := map[string]bool{}
+		for _, id := range selfIdentifiers(r) {
+			self[id] = true
+		}
+
+		referenced := map[int]bool{}
+
+		for _, s := range stringValues(*state) {
+			if self[s] || len(s) < minIdentifierLength {
+				continue
+			}
+
+			for _, j := range identifiers[s] {
+				if j == i || referenced[j] {
+					continue
+				}
+
+				if !isARN(s) && scopeOf(r) != scopeOf(resources[j]) {
+					continue
+				}
+
+				referenced[j] = true
+				g.references[i] = append(g.references[i], j)
+				g.numDependents[j]++
+			}
+		}
+	}
+
+	return g
+}
+
+// Layers returns the resources of the graph in the order they can be destroyed: the resources of each layer
+// don't depend on each other and can be destroyed concurrently, but only after all resources in the
+// previous layers have been destroyed.
+//
+// If the graph contains cycles, the cycle is broken by choosing the resources of the resource type
+// that comes first in the DependencyOrder.
+func (g *Graph) Layers() [][]terradozerRes.DestroyableResource {
+	var result [][]terradozerRes.DestroyableResource
+
+	numDependents := make(map[int]int, len(g.numDependents))
+	for k, v := range g.numDependents {
+		numDependents[k] = v
+	}
+
+	remaining := map[int]bool{}
+	for i := range g.resources {
+		remaining[i] = true
+	}
+
+	for len(remaining) > 0 {
+		var layer []int
+
+		for i := range remaining {
+			if numDependents[i] == 0 {
+				layer = append(layer, i)
+			}
+		}
+
+		if len(layer) == 0 {
+			layer = g.breakCycle(remaining)
+		}
+
+		sort.Ints(layer)
+
+		resources := make([]terradozerRes.DestroyableResource, 0, len(layer))
+
+		for _, i := range layer {
+			delete(remaining, i)
+
+			for _, j := range g.references[i] {
+				numDependents[j]--
+			}
+
+			resources = append(resources, g.resources[i])
+		}
+
+		result = append(result, resources)
+	}
+
+	return result
+}
+
+// breakCycle returns the remaining resources of the type with the highest DependencyOrder.
+func (g *Graph) breakCycle(remaining map[int]bool) []int {
+	var result []int
+
+	highest := 0
+	first := true
+
+	for i := range remaining {
+		order := DependencyOrder[g.resources[i].Type()]
+
+		switch {
+		case first || order > highest:
+			highest = order
+			result = []int{i}
+			first = false
+		case order == highest:
+			result = append(result, i)
+		}
+	}
+
+	return result
+}
+
+// identifiable is a resource with an ID, which may also have a state (e.g., a destroyable or updatable resource).
+type identifiable interface {
+	ID() string
+}
+
+// selfIdentifiers returns the values by which other resources can reference the given resource.
+func selfIdentifiers(r identifiable) []string {
+	result := []string{r.ID()}
+
+	state := stateOf(r)
+	if state == nil || state.IsNull() || !state.IsKnown() || !state.Type().IsObjectType() {
+		return result
+	}
+
+	// the id attribute can differ from the ID used to import a resource (e.g., the ID of a Route53 zone
+	// is listed as /hostedzone/<id>, but referenced as <id>)
+	for _, attr := range []string{"id", "arn"} {
+		if !state.Type().HasAttribute(attr) {
+			continue
+		}
+
+		v := state.GetAttr(attr)
+		if v.IsKnown() && !v.IsNull() && v.Type() == cty.String && v.AsString() != "" && v.AsString() != r.ID() {
+			result = append(result, v.AsString())
+		}
+	}
+
+	return result
+}
+
+// scopeOf returns the account and region of a resource, or an empty one if the resource doesn't know it
+// (see AsDestroyable).
+func scopeOf(r terradozerRes.DestroyableResource) AccountRegion {
+	s, ok := r.(scopedResource)
+	if !ok {
+		return AccountRegion{}
+	}
+
+	return s.scope
+}
+
+func isARN(s string) bool {
+	return strings.HasPrefix(s, "arn:")
+}
+
+// stateOf returns the Terraform state of a resource or nil, if the resource doesn't store a state.
+func stateOf(r identifiable) *cty.Value {
+	s, ok := r.(interface{ State() *cty.Value })
+	if !ok {
+		return nil
+	}
+
+	return s.State()
+}
+
+// stringValues returns all non-empty string values nested in the given value, except of tags.
+func stringValues(v cty.Value) []string {
+	var result []string
+
+	if v.IsNull() || !v.IsKnown() {
+		return result
+	}
+
+	switch {
+	case v.Type() == cty.String:
+		if v.AsString() != "" {
+			result = append(result, v.AsString())
+		}
+	case v.Type().IsObjectType() || v.Type().IsMapType():
+		for k, attr := range v.AsValueMap() {
+			if k == "tags" || k == "tags_all" {
+				continue
+			}
+
+			result = append(result, stringValues(attr)...)
+		}
+	case v.CanIterateElements():
+		for _, elem := range v.AsValueSlice() {
+			result = append(result, stringValues(elem)...)
+		}
+	}
+
+	return result
+}
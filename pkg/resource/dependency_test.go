@@ -0,0 +1,183 @@
+package resource_test
+
+import (
+	"testing"
+
+	"github.com/jckuester/awstools-lib/terraform"
+	"github.com/jckuester/awsweeper/pkg/resource"
+	terradozerRes "github.com/jckuester/terradozer/pkg/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestGraph_Layers(t *testing.T) {
+	vpc := terradozerRes.NewWithState("aws_vpc", "vpc-1", nil, ctyValuePtr(cty.ObjectVal(map[string]cty.Value{
+		"id":  cty.StringVal("vpc-1"),
+		"arn": cty.StringVal("arn:aws:ec2:us-west-2:123456789012:vpc/vpc-1"),
+	})))
+	subnet := terradozerRes.NewWithState("aws_subnet", "subnet-1", nil, ctyValuePtr(cty.ObjectVal(map[string]cty.Value{
+		"id":     cty.StringVal("subnet-1"),
+		"vpc_id": cty.StringVal("vpc-1"),
+	})))
+	sg := terradozerRes.NewWithState("aws_security_group", "sg-1", nil, ctyValuePtr(cty.ObjectVal(map[string]cty.Value{
+		"id":     cty.StringVal("sg-1"),
+		"vpc_id": cty.StringVal("vpc-1"),
+		"tags":   cty.MapVal(map[string]cty.Value{"foo": cty.StringVal("subnet-1")}),
+	})))
+	instance := terradozerRes.NewWithState("aws_instance", "i-1", nil, ctyValuePtr(cty.ObjectVal(map[string]cty.Value{
+		"id":              cty.StringVal("i-1"),
+		"subnet_id":       cty.StringVal("subnet-1"),
+		"security_groups": cty.SetVal([]cty.Value{cty.StringVal("sg-1")}),
+	})))
+	bucket := terradozerRes.NewWithState("aws_s3_bucket", "bucket", nil, ctyValuePtr(cty.ObjectVal(map[string]cty.Value{
+		"id": cty.StringVal("bucket"),
+	})))
+	flowLog := terradozerRes.NewWithState("aws_flow_log", "fl-1", nil, ctyValuePtr(cty.ObjectVal(map[string]cty.Value{
+		"id":              cty.StringVal("fl-1"),
+		"log_destination": cty.StringVal("arn:aws:ec2:us-west-2:123456789012:vpc/vpc-1"),
+	})))
+
+	tests := []struct {
+		name      string
+		resources []terradozerRes.DestroyableResource
+		want      [][]string
+	}{
+		{
+			name: "no resources",
+		},
+		{
+			name:      "independent resources",
+			resources: []terradozerRes.DestroyableResource{vpc, bucket},
+			want:      [][]string{{"vpc-1", "bucket"}},
+		},
+		{
+			name:      "dependencies",
+			resources: []terradozerRes.DestroyableResource{vpc, subnet, sg, instance, bucket},
+			want:      [][]string{{"i-1", "bucket"}, {"subnet-1", "sg-1"}, {"vpc-1"}},
+		},
+		{
+			name:      "reference by ARN",
+			resources: []terradozerRes.DestroyableResource{vpc, flowLog},
+			want:      [][]string{{"fl-1"}, {"vpc-1"}},
+		},
+		{
+			name: "reference by id attribute",
+			resources: []terradozerRes.DestroyableResource{
+				terradozerRes.NewWithState("aws_route53_zone", "/hostedzone/Z123456", nil,
+					ctyValuePtr(cty.ObjectVal(map[string]cty.Value{
+						"id": cty.StringVal("Z123456"),
+					}))),
+				terradozerRes.NewWithState("aws_route53_record", "Z123456_example.com_MX", nil,
+					ctyValuePtr(cty.ObjectVal(map[string]cty.Value{
+						"id":      cty.StringVal("Z123456_example.com_MX"),
+						"zone_id": cty.StringVal("Z123456"),
+					}))),
+			},
+			want: [][]string{{"Z123456_example.com_MX"}, {"/hostedzone/Z123456"}},
+		},
+		{
+			name: "short values are no references",
+			resources: []terradozerRes.DestroyableResource{
+				terradozerRes.NewWithState("aws_iam_role", "ci", nil, ctyValuePtr(cty.ObjectVal(map[string]cty.Value{
+					"id": cty.StringVal("ci"),
+				}))),
+				terradozerRes.NewWithState("aws_iam_user", "ci-user", nil, ctyValuePtr(cty.ObjectVal(map[string]cty.Value{
+					"id":   cty.StringVal("ci-user"),
+					"path": cty.StringVal("ci"),
+				}))),
+			},
+			want: [][]string{{"ci", "ci-user"}},
+		},
+		{
+			name: "resource without state",
+			resources: []terradozerRes.DestroyableResource{
+				vpc,
+				terradozerRes.New("aws_subnet", "subnet-2", nil, nil),
+			},
+			want: [][]string{{"vpc-1", "subnet-2"}},
+		},
+		{
+			name: "cycle is broken by dependency order",
+			resources: []terradozerRes.DestroyableResource{
+				terradozerRes.NewWithState("aws_vpc", "vpc-2", nil, ctyValuePtr(cty.ObjectVal(map[string]cty.Value{
+					"id":                        cty.StringVal("vpc-2"),
+					"default_security_group_id": cty.StringVal("sg-2"),
+				}))),
+				terradozerRes.NewWithState("aws_security_group", "sg-2", nil, ctyValuePtr(cty.ObjectVal(map[string]cty.Value{
+					"id":     cty.StringVal("sg-2"),
+					"vpc_id": cty.StringVal("vpc-2"),
+				}))),
+			},
+			want: [][]string{{"sg-2"}, {"vpc-2"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got [][]string
+
+			for _, layer := range resource.NewGraph(tt.resources).Layers() {
+				var ids []string
+				for _, r := range layer {
+					ids = append(ids, r.ID())
+				}
+				got = append(got, ids)
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestGraph_LayersAcrossAccounts(t *testing.T) {
+	newResource := func(rType, id, accountID string, attrs map[string]cty.Value) terraform.Resource {
+		attrs["id"] = cty.StringVal(id)
+
+		return terraform.Resource{
+			Type:              rType,
+			ID:                id,
+			AccountID:         accountID,
+			Region:            "us-west-2",
+			UpdatableResource: terradozerRes.NewWithState(rType, id, nil, ctyValuePtr(cty.ObjectVal(attrs))),
+		}
+	}
+
+	role := newResource("aws_iam_role", "deploy", "111111111111", map[string]cty.Value{
+		"arn": cty.StringVal("arn:aws:iam::111111111111:role/deploy"),
+	})
+	// role with the same name in another account
+	otherRole := newResource("aws_iam_role", "deploy", "222222222222", map[string]cty.Value{
+		"arn": cty.StringVal("arn:aws:iam::222222222222:role/deploy"),
+	})
+	profile := newResource("aws_iam_instance_profile", "deploy-profile", "222222222222", map[string]cty.Value{
+		"role": cty.StringVal("deploy"),
+	})
+	lambda := newResource("aws_lambda_function", "deploy-lambda", "333333333333", map[string]cty.Value{
+		"role": cty.StringVal("arn:aws:iam::111111111111:role/deploy"),
+	})
+
+	layerIDs := func(res ...terraform.Resource) [][]string {
+		var result [][]string
+
+		for _, layer := range resource.NewGraph(resource.Destroyable(res)).Layers() {
+			var ids []string
+			for _, r := range layer {
+				ids = append(ids, r.ID())
+			}
+			result = append(result, ids)
+		}
+
+		return result
+	}
+
+	// an ID only references resources in the same account and region
+	assert.Equal(t, [][]string{
+		{"deploy", "deploy-profile"},
+		{"deploy"},
+	}, layerIDs(role, otherRole, profile))
+
+	// an ARN references resources in any account and region
+	assert.Equal(t, [][]string{
+		{"deploy-profile", "deploy-lambda"},
+		{"deploy", "deploy"},
+	}, layerIDs(role, otherRole, profile, lambda))
+}
@@ -0,0 +1,201 @@
+package resource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/apex/log"
+	terradozerRes "github.com/jckuester/terradozer/pkg/resource"
+)
+
+// dependencyErrorCode matches the AWS error codes indicating that a resource can't be destroyed (yet),
+// because another resource still depends on it. The Terraform AWS Provider reports AWS errors
+// as "<code>: <message>", so a code is only matched as a whole word followed by a colon.
+var dependencyErrorCode = regexp.MustCompile(
+	`(^|[^\w.])(DependencyViolation|ResourceInUse|ResourceInUseException|DeleteConflict):`)
+
+// ErrDestroyTimeout is the error of a resource whose deletion didn't finish within the timeout of the provider.
+var ErrDestroyTimeout = errors.New("destroy timed out")
+
+// DestroyError is the reason why a resource could not be destroyed.
+type DestroyError struct {
+	Resource terradozerRes.DestroyableResource
+	Err      error
+}
+
+func (e DestroyError) Error() string {
+	return e.Err.Error()
+}
+
+func (e DestroyError) Unwrap() error {
+	return e.Err
+}
+
+// IsTimeout checks whether the deletion of the resource didn't finish within the timeout of the provider.
+func (e DestroyError) IsTimeout() bool {
+	return errors.Is(e.Err, ErrDestroyTimeout)
+}
+
+// IsDependencyError returns true if the resource could not be destroyed, because another resource
+// still depends on it.
+func (e DestroyError) IsDependencyError() bool {
+	return dependencyErrorCode.MatchString(e.Err.Error())
+}
+
+// DestroyResult is the outcome of destroying a list of resources.
+type DestroyResult struct {
+	Destroyed []terradozerRes.DestroyableResource
+	// Failed contains resources that are still left after the last round.
+	Failed []DestroyError
+	// Rounds is the number of rounds it took to destroy the resources.
+	Rounds int
+}
+
+// DestroyResources destroys the given resources in multiple rounds.
+//
+// In each round, resources are destroyed layer by layer in the order of their dependency graph.
+// As long as a round destroys at least one resource, all resources that failed are retried in the next round,
+// since their failure may have been caused by a resource that has been destroyed meanwhile (the provider often
+// reports this as a timeout after retrying a DependencyViolation internally). Timed out resources are also retried
+// after a round without progress, since their deletion may still finish. Retrying stops when all resources are
+// destroyed, nothing is left to retry, or the maximum number of rounds is reached. At least one round is done.
+//
+// The errors of the last attempt to destroy a resource are returned; they are classified (see IsDependencyError and
+// IsTimeout) only to report them.
+//
+// The context cancels the deletion of resources that support it (see ContextDestroyer).
+func DestroyResources(ctx context.Context, resources []terradozerRes.DestroyableResource,
+	parallel, maxRounds int) DestroyResult {
+	var result DestroyResult
+
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	if maxRounds < 1 {
+		maxRounds = 1
+	}
+
+	remaining := resources
+
+	// retryErrs are the reasons why the remaining resources could not be destroyed in the last round
+	var retryErrs []DestroyError
+
+	for len(remaining) > 0 && result.Rounds < maxRounds {
+		result.Rounds++
+
+		log.WithField("round", result.Rounds).Debugf("start destroying %d resources", len(remaining))
+
+		destroyed, failed := destroyRound(ctx, remaining, parallel)
+		result.Destroyed = append(result.Destroyed, destroyed...)
+
+		remaining = nil
+		retryErrs = nil
+
+		for _, err := range failed {
+			if len(destroyed) > 0 || err.IsTimeout() {
+				remaining = append(remaining, err.Resource)
+				retryErrs = append(retryErrs, err)
+
+				continue
+			}
+
+			result.Failed = append(result.Failed, err)
+		}
+	}
+
+	result.Failed = append(result.Failed, retryErrs...)
+
+	return result
+}
+
+// destroyRound destroys the given resources once, layer by layer.
+func destroyRound(ctx context.Context, resources []terradozerRes.DestroyableResource,
+	parallel int) ([]terradozerRes.DestroyableResource, []DestroyError) {
+	var destroyed []terradozerRes.DestroyableResource
+	var failed []DestroyError
+
+	for _, layer := range NewGraph(resources).Layers() {
+		d, f := destroyLayer(ctx, layer, parallel)
+
+		destroyed = append(destroyed, d...)
+		failed = append(failed, f...)
+	}
+
+	return destroyed, failed
+}
+
+// destroyLayer destroys the given resources concurrently.
+func destroyLayer(ctx context.Context, resources []terradozerRes.DestroyableResource,
+	parallel int) ([]terradozerRes.DestroyableResource, []DestroyError) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	var destroyed []terradozerRes.DestroyableResource
+	var failed []DestroyError
+
+	jobs := make(chan terradozerRes.DestroyableResource, len(resources))
+	for _, r := range resources {
+		jobs <- r
+	}
+
+	close(jobs)
+
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for r := range jobs {
+				err := destroy(ctx, r)
+
+				mu.Lock()
+				if err != nil {
+					failed = append(failed, DestroyError{Resource: r, Err: destroyError(err)})
+				} else {
+					destroyed = append(destroyed, r)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return destroyed, failed
+}
+
+// ContextDestroyer is a resource whose deletion can be canceled via a context (e.g., APIResource).
+// Terraform resources are destroyed via the provider, which has its own timeout (see --timeout).
+type ContextDestroyer interface {
+	DestroyContext(ctx context.Context) error
+}
+
+// destroy destroys a resource, which is canceled with the given context if the resource supports it.
+func destroy(ctx context.Context, r terradozerRes.DestroyableResource) error {
+	if d, ok := r.(ContextDestroyer); ok {
+		return d.DestroyContext(ctx)
+	}
+
+	return r.Destroy()
+}
+
+// destroyError returns the original error of a failed destroy. A timeout of the provider,
+// which the provider only reports as text, is returned as ErrDestroyTimeout.
+func destroyError(err error) error {
+	var retryErr *terradozerRes.RetryDestroyError
+	if errors.As(err, &retryErr) {
+		err = retryErr.Err
+	}
+
+	if strings.HasPrefix(err.Error(), ErrDestroyTimeout.Error()) {
+		return fmt.Errorf("%w%s", ErrDestroyTimeout, strings.TrimPrefix(err.Error(), ErrDestroyTimeout.Error()))
+	}
+
+	return err
+}
@@ -0,0 +1,280 @@
+package resource_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/jckuester/awstools-lib/terraform"
+	"github.com/jckuester/awsweeper/pkg/resource"
+	terradozerRes "github.com/jckuester/terradozer/pkg/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// fakeResource fails to be destroyed as long as any of the resources it is a dependency for still exist.
+type fakeResource struct {
+	id         string
+	dependents []string
+	err        error
+	// failures is the number of attempts that fail with err before the resource can be destroyed
+	// (0 means all attempts fail)
+	failures int
+	existing *existingResources
+}
+
+type existingResources struct {
+	sync.Mutex
+	ids      map[string]bool
+	attempts map[string]int
+}
+
+func (r fakeResource) Destroy() error {
+	r.existing.Lock()
+	defer r.existing.Unlock()
+
+	if r.err != nil {
+		r.existing.attempts[r.id]++
+
+		if r.failures == 0 || r.existing.attempts[r.id] <= r.failures {
+			return r.err
+		}
+	}
+
+	for _, d := range r.dependents {
+		if r.existing.ids[d] {
+			return errors.New("DependencyViolation: resource has a dependent object")
+		}
+	}
+
+	delete(r.existing.ids, r.id)
+
+	return nil
+}
+
+func (r fakeResource) Type() string {
+	return "aws_foo"
+}
+
+func (r fakeResource) ID() string {
+	return r.id
+}
+
+func TestDestroyResources(t *testing.T) {
+	tests := []struct {
+		name           string
+		resources      []fakeResource
+		maxRounds      int
+		wantDestroyed  []string
+		wantFailed     []string
+		wantFailedErrs []string
+		wantRounds     int
+	}{
+		{
+			name: "no dependencies",
+			resources: []fakeResource{
+				{id: "a"},
+				{id: "b"},
+			},
+			maxRounds:     5,
+			wantDestroyed: []string{"a", "b"},
+			wantRounds:    1,
+		},
+		{
+			name: "chain of dependencies",
+			resources: []fakeResource{
+				{id: "a", dependents: []string{"b"}},
+				{id: "b", dependents: []string{"c"}},
+				{id: "c"},
+			},
+			maxRounds:     5,
+			wantDestroyed: []string{"a", "b", "c"},
+			wantRounds:    3,
+		},
+		{
+			name: "max rounds exceeded",
+			resources: []fakeResource{
+				{id: "a", dependents: []string{"b"}},
+				{id: "b", dependents: []string{"c"}},
+				{id: "c"},
+			},
+			maxRounds:      1,
+			wantDestroyed:  []string{"c"},
+			wantFailed:     []string{"a", "b"},
+			wantFailedErrs: []string{"DependencyViolation: resource has a dependent object"},
+			wantRounds:     1,
+		},
+		{
+			name: "errors are not retried after a round without progress",
+			resources: []fakeResource{
+				{id: "a", dependents: []string{"b"}},
+				{id: "b", err: errors.New("AccessDenied")},
+			},
+			maxRounds:      5,
+			wantFailed:     []string{"a", "b"},
+			wantFailedErrs: []string{"AccessDenied", "DependencyViolation: resource has a dependent object"},
+			wantRounds:     1,
+		},
+		{
+			name: "any error is retried after a round with progress",
+			resources: []fakeResource{
+				{id: "a", dependents: []string{"b"}},
+				{id: "b", err: errors.New("Throttling: rate exceeded"), failures: 1},
+				{id: "c"},
+			},
+			maxRounds:     5,
+			wantDestroyed: []string{"a", "b", "c"},
+			wantRounds:    3,
+		},
+		{
+			name: "timeouts are retried after a round without progress",
+			resources: []fakeResource{
+				{id: "a", err: errors.New("destroy timed out (20m0s)"), failures: 2},
+			},
+			maxRounds:     5,
+			wantDestroyed: []string{"a"},
+			wantRounds:    3,
+		},
+		{
+			name: "timeouts are retried until max rounds",
+			resources: []fakeResource{
+				{id: "a", err: errors.New("destroy timed out (20m0s)")},
+			},
+			maxRounds:      2,
+			wantFailed:     []string{"a"},
+			wantFailedErrs: []string{"destroy timed out (20m0s)"},
+			wantRounds:     2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing := &existingResources{ids: map[string]bool{}, attempts: map[string]int{}}
+
+			var resources []terradozerRes.DestroyableResource
+			for _, r := range tt.resources {
+				r.existing = existing
+				existing.ids[r.id] = true
+				resources = append(resources, r)
+			}
+
+			result := resource.DestroyResources(context.Background(), resources, 1, tt.maxRounds)
+
+			var destroyed []string
+			for _, r := range result.Destroyed {
+				destroyed = append(destroyed, r.ID())
+			}
+			sort.Strings(destroyed)
+
+			var failed []string
+			errs := map[string]bool{}
+			for _, err := range result.Failed {
+				failed = append(failed, err.Resource.ID())
+				errs[err.Error()] = true
+			}
+			sort.Strings(failed)
+
+			var failedErrs []string
+			for err := range errs {
+				failedErrs = append(failedErrs, err)
+			}
+			sort.Strings(failedErrs)
+
+			assert.Equal(t, tt.wantDestroyed, destroyed)
+			assert.Equal(t, tt.wantFailed, failed)
+			assert.Equal(t, tt.wantFailedErrs, failedErrs)
+			if tt.wantRounds > 0 {
+				require.Equal(t, tt.wantRounds, result.Rounds)
+			}
+		})
+	}
+}
+
+func TestDestroyResources_NoParallelism(t *testing.T) {
+	existing := &existingResources{ids: map[string]bool{"a": true}, attempts: map[string]int{}}
+
+	result := resource.DestroyResources(context.Background(), []terradozerRes.DestroyableResource{
+		fakeResource{id: "a", existing: existing},
+	}, 0, 0)
+
+	require.Len(t, result.Destroyed, 1)
+	assert.Empty(t, result.Failed)
+}
+
+func TestDestroyResources_Context(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	r, ok := resource.AsDestroyable(terraform.Resource{
+		Type: "aws_ecr_images",
+		ID:   "app/*",
+		UpdatableResource: resource.NewAPIResource("aws_ecr_images", "app/*", cty.EmptyObjectVal,
+			func(ctx context.Context) error {
+				return ctx.Err()
+			}),
+	})
+	require.True(t, ok)
+
+	result := resource.DestroyResources(ctx, []terradozerRes.DestroyableResource{r}, 1, 1)
+
+	assert.Empty(t, result.Destroyed)
+	require.Len(t, result.Failed, 1)
+	assert.True(t, errors.Is(result.Failed[0].Err, context.Canceled))
+}
+
+func TestDestroyError(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		wantDependency bool
+		wantTimeout    bool
+	}{
+		{
+			name:           "dependency violation",
+			err:            errors.New("error deleting VPC: DependencyViolation: The vpc 'vpc-1' has dependencies"),
+			wantDependency: true,
+		},
+		{
+			name:           "delete conflict",
+			err:            errors.New("error deleting IAM role: DeleteConflict: Cannot delete entity"),
+			wantDependency: true,
+		},
+		{
+			name:           "resource in use",
+			err:            errors.New("error deleting ECS cluster: ResourceInUseException: cluster has services"),
+			wantDependency: true,
+		},
+		{
+			name: "text mentioning in use",
+			err:  errors.New("error deleting key pair: InvalidKeyPair.NotFound: key pair not in use or not found"),
+		},
+		{
+			name: "other error code containing InUse",
+			err:  errors.New("error releasing EIP: InvalidIPAddress.InUse: address is in use"),
+		},
+		{
+			name:        "timeout",
+			err:         errors.New("destroy timed out (30s)"),
+			wantTimeout: true,
+		},
+		{
+			name:        "timeout after retries",
+			err:         &terradozerRes.RetryDestroyError{Err: errors.New("destroy timed out (30s)")},
+			wantTimeout: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := resource.DestroyResources(context.Background(), []terradozerRes.DestroyableResource{
+				fakeResource{id: "a", err: tt.err, existing: &existingResources{attempts: map[string]int{}}},
+			}, 1, 1)
+
+			require.Len(t, result.Failed, 1)
+			assert.Equal(t, tt.wantDependency, result.Failed[0].IsDependencyError())
+			assert.Equal(t, tt.wantTimeout, result.Failed[0].IsTimeout())
+			assert.Equal(t, tt.wantTimeout, errors.Is(result.Failed[0], resource.ErrDestroyTimeout))
+		})
+	}
+}
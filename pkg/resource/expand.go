@@ -0,0 +1,202 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/efs"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/jckuester/awstools-lib/aws"
+	"github.com/jckuester/awstools-lib/terraform"
+	"github.com/jckuester/terradozer/pkg/provider"
+	terradozerRes "github.com/jckuester/terradozer/pkg/resource"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Expander discovers resources of a child type that belong to resources of a parent type, but are not listed
+// on their own (e.g., the policy attachments of IAM users). Child resources are deleted together with their parents.
+type Expander struct {
+	// ChildType is the type of the resources the expander produces.
+	ChildType string
+	// Expand returns the child resources of the given parent resource.
+	Expand func(ctx context.Context, clients ExpanderClients, parent terraform.Resource) ([]Child, []error)
+	// Enabled returns whether the expander is enabled by the options of the config (nil means always enabled).
+	Enabled func(o Options) bool
+}
+
+// Child is a resource discovered by an expander, whose Terraform state is read afterwards.
+type Child struct {
+	Type string
+	ID   string
+	// Attributes are needed to read the state of resources that cannot be imported by their ID alone.
+	Attributes map[string]cty.Value
+	// Resource is set for resources that are not Terraform resources (e.g., ECR images),
+	// which are deleted via the AWS API instead of the Terraform AWS Provider.
+	Resource *APIResource
+}
+
+// APIResource is a resource that is not a Terraform resource, but is deleted via the AWS API instead of
+// the Terraform AWS Provider. Its state is known when it is listed and only used to find its dependencies.
+type APIResource struct {
+	rType   string
+	id      string
+	state   cty.Value
+	destroy func(ctx context.Context) error
+}
+
+// NewAPIResource creates a resource that is deleted via the given function.
+func NewAPIResource(rType, id string, state cty.Value, destroy func(ctx context.Context) error) *APIResource {
+	return &APIResource{
+		rType:   rType,
+		id:      id,
+		state:   state,
+		destroy: destroy,
+	}
+}
+
+func (r *APIResource) Type() string {
+	return r.rType
+}
+
+func (r *APIResource) ID() string {
+	return r.id
+}
+
+func (r *APIResource) State() *cty.Value {
+	state := r.state
+
+	return &state
+}
+
+// UpdateState does nothing, since the state is known when the resource is listed.
+func (r *APIResource) UpdateState() error {
+	return nil
+}
+
+// Destroy deletes the resource via the AWS API without a deadline (see DestroyContext).
+func (r *APIResource) Destroy() error {
+	return r.destroy(context.Background())
+}
+
+// DestroyContext deletes the resource via the AWS API, which is canceled with the given context.
+func (r *APIResource) DestroyContext(ctx context.Context) error {
+	return r.destroy(ctx)
+}
+
+// IAMAPI is the part of the IAM API used by expanders.
+type IAMAPI interface {
+	iam.ListAttachedUserPoliciesAPIClient
+	iam.ListUserPoliciesAPIClient
+	iam.ListAttachedRolePoliciesAPIClient
+	iam.ListRolePoliciesAPIClient
+	iam.ListInstanceProfilesForRoleAPIClient
+	RemoveRoleFromInstanceProfile(ctx context.Context, params *iam.RemoveRoleFromInstanceProfileInput,
+		optFns ...func(*iam.Options)) (*iam.RemoveRoleFromInstanceProfileOutput, error)
+	iam.ListAttachedGroupPoliciesAPIClient
+	iam.ListGroupPoliciesAPIClient
+	iam.GetGroupAPIClient
+}
+
+// EFSAPI is the part of the EFS API used by expanders.
+type EFSAPI interface {
+	DescribeMountTargets(ctx context.Context, params *efs.DescribeMountTargetsInput,
+		optFns ...func(*efs.Options)) (*efs.DescribeMountTargetsOutput, error)
+}
+
+// ECRAPI is the part of the ECR API used by expanders.
+type ECRAPI interface {
+	ecr.DescribeImagesAPIClient
+	BatchDeleteImage(ctx context.Context, params *ecr.BatchDeleteImageInput,
+		optFns ...func(*ecr.Options)) (*ecr.BatchDeleteImageOutput, error)
+}
+
+// Route53API is the part of the Route53 API used by expanders.
+type Route53API interface {
+	ListResourceRecordSets(ctx context.Context, params *route53.ListResourceRecordSetsInput,
+		optFns ...func(*route53.Options)) (*route53.ListResourceRecordSetsOutput, error)
+}
+
+// ExpanderClients are the AWS API clients used by expanders. They are interfaces, so that expanders
+// can be tested with fake clients.
+type ExpanderClients struct {
+	IAM     IAMAPI
+	EFS     EFSAPI
+	ECR     ECRAPI
+	Route53 Route53API
+	S3      S3API
+}
+
+// NewExpanderClients returns the API clients of an AWS client that are used by expanders.
+func NewExpanderClients(client aws.Client) ExpanderClients {
+	return ExpanderClients{
+		IAM:     client.Iamconn,
+		EFS:     client.Efsconn,
+		ECR:     client.Ecrconn,
+		Route53: client.Route53conn,
+		S3:      client.S3conn,
+	}
+}
+
+// expanders are the registered expanders by parent type.
+var expanders = map[string][]Expander{}
+
+// RegisterExpander registers an expander of child resources for a parent type. The expanders of a type
+// run in the order they have been registered.
+func RegisterExpander(parentType string, e Expander) {
+	for _, existing := range expanders[parentType] {
+		if existing.ChildType == e.ChildType {
+			panic(fmt.Sprintf("expander of %s for %s already registered", e.ChildType, parentType))
+		}
+	}
+
+	expanders[parentType] = append(expanders[parentType], e)
+}
+
+// Expanders returns the expanders registered for a parent type.
+func Expanders(parentType string) []Expander {
+	return expanders[parentType]
+}
+
+// isChildType checks whether resources of the given type are listed as dependent resources (see Expander).
+func isChildType(rType string) bool {
+	for _, es := range expanders {
+		for _, e := range es {
+			if e.ChildType == rType {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// updateChildStates reads the Terraform states of the given child resources.
+func updateChildStates(children []Child, provider *provider.TerraformProvider) ([]terraform.Resource, []error) {
+	var result []terraform.Resource
+	var errs []error
+
+	for _, c := range children {
+		r := terraform.Resource{
+			Type: c.Type,
+			ID:   c.ID,
+		}
+
+		if c.Resource != nil {
+			r.UpdatableResource = c.Resource
+		} else {
+			r.UpdatableResource = terradozerRes.New(r.Type, r.ID, c.Attributes, provider)
+		}
+
+		err := r.UpdateState()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		result = append(result, r)
+	}
+
+	return result, errs
+}
@@ -0,0 +1,115 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	"github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/jckuester/awstools-lib/terraform"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// maxBatchDeleteImages is the maximum number of images that can be deleted with one request.
+const maxBatchDeleteImages = 100
+
+func init() {
+	RegisterExpander("aws_ecr_repository", Expander{
+		ChildType: "aws_ecr_images",
+		Expand:    expandEcrImages,
+	})
+}
+
+// expandEcrImages returns the images of an ECR repository as one resource (with ID <repository>/*), which is
+// deleted before the repository. Images are not Terraform resources, so they are deleted via the ECR API
+// in batches.
+func expandEcrImages(ctx context.Context, clients ExpanderClients,
+	repository terraform.Resource) ([]Child, []error) {
+	var digests []string
+
+	pg := ecr.NewDescribeImagesPaginator(clients.ECR, &ecr.DescribeImagesInput{
+		RepositoryName: &repository.ID,
+	})
+
+	for pg.HasMorePages() {
+		page, err := pg.NextPage(ctx)
+		if err != nil {
+			return nil, []error{err}
+		}
+
+		for _, image := range page.ImageDetails {
+			digests = append(digests, *image.ImageDigest)
+		}
+	}
+
+	if len(digests) == 0 {
+		return nil, nil
+	}
+
+	repositoryName := repository.ID
+	id := repositoryName + "/*"
+
+	return []Child{{
+		Type: "aws_ecr_images",
+		ID:   id,
+		Resource: NewAPIResource("aws_ecr_images", id, ecrImagesState(id, repositoryName, digests),
+			func(ctx context.Context) error {
+				return deleteEcrImages(ctx, clients.ECR, repositoryName, digests)
+			}),
+	}}, nil
+}
+
+// ecrImagesState returns a state that references the repository, so that the images are deleted
+// before their repository.
+func ecrImagesState(id, repositoryName string, digests []string) cty.Value {
+	values := make([]cty.Value, 0, len(digests))
+	for _, d := range digests {
+		values = append(values, cty.StringVal(d))
+	}
+
+	return cty.ObjectVal(map[string]cty.Value{
+		"id":              cty.StringVal(id),
+		"repository_name": cty.StringVal(repositoryName),
+		"image_digests":   cty.ListVal(values),
+	})
+}
+
+// deleteEcrImages deletes images with all their tags, up to maxBatchDeleteImages per request.
+// An image that is already deleted is not an error.
+func deleteEcrImages(ctx context.Context, client ECRAPI, repositoryName string, digests []string) error {
+	for start := 0; start < len(digests); start += maxBatchDeleteImages {
+		end := start + maxBatchDeleteImages
+		if end > len(digests) {
+			end = len(digests)
+		}
+
+		ids := make([]types.ImageIdentifier, 0, end-start)
+		for i := range digests[start:end] {
+			ids = append(ids, types.ImageIdentifier{ImageDigest: &digests[start+i]})
+		}
+
+		output, err := client.BatchDeleteImage(ctx, &ecr.BatchDeleteImageInput{
+			RepositoryName: &repositoryName,
+			ImageIds:       ids,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, f := range output.Failures {
+			if f.FailureCode == types.ImageFailureCodeImageNotFound {
+				continue
+			}
+
+			var digest string
+			if f.ImageId != nil {
+				digest = stringValue(f.ImageId.ImageDigest)
+			}
+
+			return fmt.Errorf("failed to delete image (digest=%s): %s: %s", digest, f.FailureCode,
+				stringValue(f.FailureReason))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package resource
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/efs"
+	"github.com/jckuester/awstools-lib/terraform"
+)
+
+func init() {
+	RegisterExpander("aws_efs_file_system", Expander{
+		ChildType: "aws_efs_mount_target",
+		Expand:    expandEfsMountTargets,
+	})
+}
+
+func expandEfsMountTargets(ctx context.Context, clients ExpanderClients,
+	fs terraform.Resource) ([]Child, []error) {
+	var result []Child
+
+	// TODO result is paginated, but there is no paginator API function
+	req, err := clients.EFS.DescribeMountTargets(ctx, &efs.DescribeMountTargetsInput{
+		FileSystemId: &fs.ID,
+	})
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	for _, mountTarget := range req.MountTargets {
+		result = append(result, Child{
+			Type: "aws_efs_mount_target",
+			ID:   *mountTarget.MountTargetId,
+		})
+	}
+
+	return result, nil
+}
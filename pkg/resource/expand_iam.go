@@ -0,0 +1,330 @@
+package resource
+
+import (
+	"context"
+	"errors"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamTypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	awslsRes "github.com/jckuester/awsls/resource"
+	"github.com/jckuester/awstools-lib/terraform"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func init() {
+	RegisterExpander("aws_iam_user", Expander{
+		ChildType: "aws_iam_user_policy_attachment",
+		Expand:    expandAttachedUserPolicies,
+	})
+	RegisterExpander("aws_iam_user", Expander{
+		ChildType: "aws_iam_user_policy",
+		Expand:    expandInlineUserPolicies,
+	})
+	RegisterExpander("aws_iam_role", Expander{
+		ChildType: "aws_iam_role_policy_attachment",
+		Expand:    expandAttachedRolePolicies,
+	})
+	RegisterExpander("aws_iam_role", Expander{
+		ChildType: "aws_iam_role_policy",
+		Expand:    expandInlineRolePolicies,
+	})
+	RegisterExpander("aws_iam_role", Expander{
+		ChildType: "aws_iam_role_instance_profile",
+		Expand:    expandRoleInstanceProfiles,
+	})
+	RegisterExpander("aws_iam_group", Expander{
+		ChildType: "aws_iam_group_membership",
+		Expand:    expandGroupMemberships,
+	})
+	RegisterExpander("aws_iam_group", Expander{
+		ChildType: "aws_iam_group_policy_attachment",
+		Expand:    expandAttachedGroupPolicies,
+	})
+	RegisterExpander("aws_iam_group", Expander{
+		ChildType: "aws_iam_group_policy",
+		Expand:    expandInlineGroupPolicies,
+	})
+	RegisterExpander("aws_iam_policy", Expander{
+		ChildType: "aws_iam_policy_attachment",
+		Expand:    expandPolicyAttachments,
+	})
+}
+
+func expandAttachedUserPolicies(ctx context.Context, clients ExpanderClients,
+	user terraform.Resource) ([]Child, []error) {
+	var result []Child
+	var errs []error
+
+	pg := iam.NewListAttachedUserPoliciesPaginator(clients.IAM, &iam.ListAttachedUserPoliciesInput{
+		UserName: &user.ID,
+	})
+
+	for pg.HasMorePages() {
+		page, err := pg.NextPage(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		for _, attachedPolicy := range page.AttachedPolicies {
+			result = append(result, Child{
+				Type: "aws_iam_user_policy_attachment",
+				ID:   *attachedPolicy.PolicyArn,
+				Attributes: map[string]cty.Value{
+					"user":       cty.StringVal(user.ID),
+					"policy_arn": cty.StringVal(*attachedPolicy.PolicyArn),
+				},
+			})
+		}
+	}
+
+	return result, errs
+}
+
+func expandInlineUserPolicies(ctx context.Context, clients ExpanderClients,
+	user terraform.Resource) ([]Child, []error) {
+	var result []Child
+	var errs []error
+
+	pg := iam.NewListUserPoliciesPaginator(clients.IAM, &iam.ListUserPoliciesInput{
+		UserName: &user.ID,
+	})
+
+	for pg.HasMorePages() {
+		page, err := pg.NextPage(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		for _, inlinePolicy := range page.PolicyNames {
+			result = append(result, Child{
+				Type: "aws_iam_user_policy",
+				ID:   user.ID + ":" + inlinePolicy,
+			})
+		}
+	}
+
+	return result, errs
+}
+
+func expandAttachedRolePolicies(ctx context.Context, clients ExpanderClients,
+	role terraform.Resource) ([]Child, []error) {
+	var result []Child
+	var errs []error
+
+	pg := iam.NewListAttachedRolePoliciesPaginator(clients.IAM, &iam.ListAttachedRolePoliciesInput{
+		RoleName: &role.ID,
+	})
+
+	for pg.HasMorePages() {
+		page, err := pg.NextPage(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		for _, attachedPolicy := range page.AttachedPolicies {
+			result = append(result, Child{
+				Type: "aws_iam_role_policy_attachment",
+				ID:   *attachedPolicy.PolicyArn,
+				Attributes: map[string]cty.Value{
+					"role":       cty.StringVal(role.ID),
+					"policy_arn": cty.StringVal(*attachedPolicy.PolicyArn),
+				},
+			})
+		}
+	}
+
+	return result, errs
+}
+
+func expandInlineRolePolicies(ctx context.Context, clients ExpanderClients,
+	role terraform.Resource) ([]Child, []error) {
+	var result []Child
+	var errs []error
+
+	pg := iam.NewListRolePoliciesPaginator(clients.IAM, &iam.ListRolePoliciesInput{
+		RoleName: &role.ID,
+	})
+
+	for pg.HasMorePages() {
+		page, err := pg.NextPage(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		for _, inlinePolicy := range page.PolicyNames {
+			result = append(result, Child{
+				Type: "aws_iam_role_policy",
+				ID:   role.ID + ":" + inlinePolicy,
+			})
+		}
+	}
+
+	return result, errs
+}
+
+// expandRoleInstanceProfiles returns the links of a role to its instance profiles. A role cannot be deleted as
+// long as it is part of an instance profile, so it is removed from the profile; the instance profile itself is
+// only deleted if the filter matches it.
+func expandRoleInstanceProfiles(ctx context.Context, clients ExpanderClients,
+	role terraform.Resource) ([]Child, []error) {
+	var result []Child
+	var errs []error
+
+	pg := iam.NewListInstanceProfilesForRolePaginator(clients.IAM, &iam.ListInstanceProfilesForRoleInput{
+		RoleName: &role.ID,
+	})
+
+	for pg.HasMorePages() {
+		page, err := pg.NextPage(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		for _, instanceProfile := range page.InstanceProfiles {
+			roleName, profileName := role.ID, *instanceProfile.InstanceProfileName
+			id := roleName + ":" + profileName
+
+			// the state references the role, so that the role is removed from the profile before it is deleted
+			state := cty.ObjectVal(map[string]cty.Value{
+				"id":               cty.StringVal(id),
+				"role":             cty.StringVal(roleName),
+				"instance_profile": cty.StringVal(profileName),
+			})
+
+			result = append(result, Child{
+				Type: "aws_iam_role_instance_profile",
+				ID:   id,
+				Resource: NewAPIResource("aws_iam_role_instance_profile", id, state,
+					func(ctx context.Context) error {
+						return removeRoleFromInstanceProfile(ctx, clients.IAM, roleName, profileName)
+					}),
+			})
+		}
+	}
+
+	return result, errs
+}
+
+// removeRoleFromInstanceProfile removes a role from an instance profile. There is no Terraform resource that links
+// a role to an instance profile (the role is an attribute of aws_iam_instance_profile), so this is done via
+// the IAM API. A role or profile that is already deleted is not an error.
+func removeRoleFromInstanceProfile(ctx context.Context, client IAMAPI, roleName, profileName string) error {
+	_, err := client.RemoveRoleFromInstanceProfile(ctx, &iam.RemoveRoleFromInstanceProfileInput{
+		RoleName:            &roleName,
+		InstanceProfileName: &profileName,
+	})
+
+	var notFound *iamTypes.NoSuchEntityException
+	if errors.As(err, &notFound) {
+		return nil
+	}
+
+	return err
+}
+
+// expandGroupMemberships returns the membership of a group if it has users. A membership covers all users
+// of a group and its ID is the name of the group.
+func expandGroupMemberships(ctx context.Context, clients ExpanderClients,
+	group terraform.Resource) ([]Child, []error) {
+	output, err := clients.IAM.GetGroup(ctx, &iam.GetGroupInput{
+		GroupName: &group.ID,
+		MaxItems:  awssdk.Int32(1),
+	})
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	if len(output.Users) == 0 {
+		return nil, nil
+	}
+
+	return []Child{{
+		Type: "aws_iam_group_membership",
+		ID:   group.ID,
+		Attributes: map[string]cty.Value{
+			"name":  cty.StringVal(group.ID),
+			"group": cty.StringVal(group.ID),
+		},
+	}}, nil
+}
+
+func expandAttachedGroupPolicies(ctx context.Context, clients ExpanderClients,
+	group terraform.Resource) ([]Child, []error) {
+	var result []Child
+	var errs []error
+
+	pg := iam.NewListAttachedGroupPoliciesPaginator(clients.IAM, &iam.ListAttachedGroupPoliciesInput{
+		GroupName: &group.ID,
+	})
+
+	for pg.HasMorePages() {
+		page, err := pg.NextPage(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		for _, attachedPolicy := range page.AttachedPolicies {
+			result = append(result, Child{
+				Type: "aws_iam_group_policy_attachment",
+				ID:   *attachedPolicy.PolicyArn,
+				Attributes: map[string]cty.Value{
+					"group":      cty.StringVal(group.ID),
+					"policy_arn": cty.StringVal(*attachedPolicy.PolicyArn),
+				},
+			})
+		}
+	}
+
+	return result, errs
+}
+
+func expandInlineGroupPolicies(ctx context.Context, clients ExpanderClients,
+	group terraform.Resource) ([]Child, []error) {
+	var result []Child
+	var errs []error
+
+	pg := iam.NewListGroupPoliciesPaginator(clients.IAM, &iam.ListGroupPoliciesInput{
+		GroupName: &group.ID,
+	})
+
+	for pg.HasMorePages() {
+		page, err := pg.NextPage(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		for _, inlinePolicy := range page.PolicyNames {
+			result = append(result, Child{
+				Type: "aws_iam_group_policy",
+				ID:   group.ID + ":" + inlinePolicy,
+			})
+		}
+	}
+
+	return result, errs
+}
+
+func expandPolicyAttachments(_ context.Context, _ ExpanderClients,
+	policy terraform.Resource) ([]Child, []error) {
+	arn, err := awslsRes.GetAttribute("arn", &policy)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	return []Child{{
+		Type: "aws_iam_policy_attachment",
+		// Note: ID is only set for pretty printing (could be also left empty)
+		ID: policy.ID,
+		Attributes: map[string]cty.Value{
+			"policy_arn": cty.StringVal(arn),
+		},
+	}}, nil
+}
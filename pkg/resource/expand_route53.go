@@ -0,0 +1,113 @@
+package resource
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	"github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/jckuester/awstools-lib/terraform"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func init() {
+	RegisterExpander("aws_route53_zone", Expander{
+		ChildType: "aws_route53_record",
+		Expand:    expandRoute53Records,
+	})
+}
+
+// expandRoute53Records returns the record sets of a hosted zone, except the NS and SOA records of the zone apex,
+// which are deleted together with the zone.
+func expandRoute53Records(ctx context.Context, clients ExpanderClients,
+	zone terraform.Resource) ([]Child, []error) {
+	var result []Child
+
+	zoneID := strings.TrimPrefix(zone.ID, "/hostedzone/")
+
+	records, err := listRecordSets(ctx, clients.Route53, zoneID)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	// the SOA record only exists at the zone apex
+	var apex string
+	for _, r := range records {
+		if r.Type == types.RRTypeSoa {
+			apex = *r.Name
+		}
+	}
+
+	for _, r := range records {
+		if *r.Name == apex && (r.Type == types.RRTypeSoa || r.Type == types.RRTypeNs) {
+			continue
+		}
+
+		name := decodeRecordName(strings.TrimSuffix(*r.Name, "."))
+
+		id := []string{zoneID, name, string(r.Type)}
+		attrs := map[string]cty.Value{
+			"zone_id": cty.StringVal(zoneID),
+			"name":    cty.StringVal(name),
+			"type":    cty.StringVal(string(r.Type)),
+		}
+
+		if r.SetIdentifier != nil {
+			id = append(id, *r.SetIdentifier)
+			attrs["set_identifier"] = cty.StringVal(*r.SetIdentifier)
+		}
+
+		result = append(result, Child{
+			Type:       "aws_route53_record",
+			ID:         strings.Join(id, "_"),
+			Attributes: attrs,
+		})
+	}
+
+	return result, nil
+}
+
+// recordNameEscapeCode matches the octal codes by which Route53 escapes characters in the names of record sets
+// other than a-z, 0-9, - (hyphen), _ (underscore) and . (period), e.g. a wildcard as \052.
+var recordNameEscapeCode = regexp.MustCompile(`\\[0-3][0-7]{2}`)
+
+// decodeRecordName returns the name of a record set as it is set in Terraform, i.e. with all escape codes decoded.
+// Characters that aren't ASCII are escaped per byte of their UTF-8 encoding.
+func decodeRecordName(name string) string {
+	return recordNameEscapeCode.ReplaceAllStringFunc(name, func(code string) string {
+		b, err := strconv.ParseUint(code[1:], 8, 8)
+		if err != nil {
+			return code
+		}
+
+		return string([]byte{byte(b)})
+	})
+}
+
+// listRecordSets returns all record sets of a hosted zone.
+func listRecordSets(ctx context.Context, client Route53API, zoneID string) ([]types.ResourceRecordSet, error) {
+	var result []types.ResourceRecordSet
+
+	input := &route53.ListResourceRecordSetsInput{
+		HostedZoneId: &zoneID,
+	}
+
+	for {
+		page, err := client.ListResourceRecordSets(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, page.ResourceRecordSets...)
+
+		if !page.IsTruncated {
+			return result, nil
+		}
+
+		input.StartRecordName = page.NextRecordName
+		input.StartRecordType = page.NextRecordType
+		input.StartRecordIdentifier = page.NextRecordIdentifier
+	}
+}
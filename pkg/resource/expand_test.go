@@ -0,0 +1,564 @@
+package resource_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrTypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+	"github.com/aws/aws-sdk-go-v2/service/efs"
+	efsTypes "github.com/aws/aws-sdk-go-v2/service/efs/types"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamTypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/route53"
+	route53Types "github.com/aws/aws-sdk-go-v2/service/route53/types"
+	"github.com/jckuester/awstools-lib/terraform"
+	"github.com/jckuester/awsweeper/pkg/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// fakeIAM returns the attached and inline policies of users, roles and groups (by name), the instance profiles
+// of roles and the users of groups, where a page of results contains one item.
+type fakeIAM struct {
+	attachedPolicies map[string][]string
+	inlinePolicies   map[string][]string
+	instanceProfiles map[string][]string
+	groupUsers       map[string][]string
+	// removedRoles are the roles removed from instance profiles by profile name
+	removedRoles map[string]string
+}
+
+func (f fakeIAM) attached(name string, marker *string) ([]iamTypes.AttachedPolicy, *string, error) {
+	policies, ok := f.attachedPolicies[name]
+	if !ok {
+		return nil, nil, errors.New("NoSuchEntity")
+	}
+
+	i, next := page(marker, len(policies))
+	if i < 0 {
+		return nil, nil, nil
+	}
+
+	return []iamTypes.AttachedPolicy{{PolicyArn: aws.String(policies[i])}}, next, nil
+}
+
+func (f fakeIAM) inline(name string, marker *string) ([]string, *string) {
+	policies := f.inlinePolicies[name]
+
+	i, next := page(marker, len(policies))
+	if i < 0 {
+		return nil, nil
+	}
+
+	return []string{policies[i]}, next
+}
+
+func (f fakeIAM) ListAttachedUserPolicies(_ context.Context, params *iam.ListAttachedUserPoliciesInput,
+	_ ...func(*iam.Options)) (*iam.ListAttachedUserPoliciesOutput, error) {
+	policies, next, err := f.attached(*params.UserName, params.Marker)
+
+	return &iam.ListAttachedUserPoliciesOutput{AttachedPolicies: policies, IsTruncated: next != nil, Marker: next}, err
+}
+
+func (f fakeIAM) ListUserPolicies(_ context.Context, params *iam.ListUserPoliciesInput,
+	_ ...func(*iam.Options)) (*iam.ListUserPoliciesOutput, error) {
+	policies, next := f.inline(*params.UserName, params.Marker)
+
+	return &iam.ListUserPoliciesOutput{PolicyNames: policies, IsTruncated: next != nil, Marker: next}, nil
+}
+
+func (f fakeIAM) ListAttachedRolePolicies(_ context.Context, params *iam.ListAttachedRolePoliciesInput,
+	_ ...func(*iam.Options)) (*iam.ListAttachedRolePoliciesOutput, error) {
+	policies, next, err := f.attached(*params.RoleName, params.Marker)
+
+	return &iam.ListAttachedRolePoliciesOutput{AttachedPolicies: policies, IsTruncated: next != nil, Marker: next}, err
+}
+
+func (f fakeIAM) ListRolePolicies(_ context.Context, params *iam.ListRolePoliciesInput,
+	_ ...func(*iam.Options)) (*iam.ListRolePoliciesOutput, error) {
+	policies, next := f.inline(*params.RoleName, params.Marker)
+
+	return &iam.ListRolePoliciesOutput{PolicyNames: policies, IsTruncated: next != nil, Marker: next}, nil
+}
+
+func (f fakeIAM) ListInstanceProfilesForRole(_ context.Context, params *iam.ListInstanceProfilesForRoleInput,
+	_ ...func(*iam.Options)) (*iam.ListInstanceProfilesForRoleOutput, error) {
+	profiles := f.instanceProfiles[*params.RoleName]
+
+	i, next := page(params.Marker, len(profiles))
+	if i < 0 {
+		return &iam.ListInstanceProfilesForRoleOutput{}, nil
+	}
+
+	return &iam.ListInstanceProfilesForRoleOutput{
+		InstanceProfiles: []iamTypes.InstanceProfile{{InstanceProfileName: aws.String(profiles[i])}},
+		IsTruncated:      next != nil,
+		Marker:           next,
+	}, nil
+}
+
+func (f fakeIAM) RemoveRoleFromInstanceProfile(_ context.Context, params *iam.RemoveRoleFromInstanceProfileInput,
+	_ ...func(*iam.Options)) (*iam.RemoveRoleFromInstanceProfileOutput, error) {
+	if _, ok := f.removedRoles[*params.InstanceProfileName]; ok {
+		return nil, &iamTypes.NoSuchEntityException{Message: aws.String("role is not part of the profile")}
+	}
+
+	f.removedRoles[*params.InstanceProfileName] = *params.RoleName
+
+	return &iam.RemoveRoleFromInstanceProfileOutput{}, nil
+}
+
+func (f fakeIAM) ListAttachedGroupPolicies(_ context.Context, params *iam.ListAttachedGroupPoliciesInput,
+	_ ...func(*iam.Options)) (*iam.ListAttachedGroupPoliciesOutput, error) {
+	policies, next, err := f.attached(*params.GroupName, params.Marker)
+
+	return &iam.ListAttachedGroupPoliciesOutput{AttachedPolicies: policies, IsTruncated: next != nil, Marker: next}, err
+}
+
+func (f fakeIAM) ListGroupPolicies(_ context.Context, params *iam.ListGroupPoliciesInput,
+	_ ...func(*iam.Options)) (*iam.ListGroupPoliciesOutput, error) {
+	policies, next := f.inline(*params.GroupName, params.Marker)
+
+	return &iam.ListGroupPoliciesOutput{PolicyNames: policies, IsTruncated: next != nil, Marker: next}, nil
+}
+
+func (f fakeIAM) GetGroup(_ context.Context, params *iam.GetGroupInput,
+	_ ...func(*iam.Options)) (*iam.GetGroupOutput, error) {
+	var users []iamTypes.User
+	for _, name := range f.groupUsers[*params.GroupName] {
+		users = append(users, iamTypes.User{UserName: aws.String(name)})
+	}
+
+	return &iam.GetGroupOutput{Users: users}, nil
+}
+
+// page returns the index of the item on the page starting at marker (-1 if there are no items)
+// and the marker of the next page (nil if it is the last one).
+func page(marker *string, n int) (int, *string) {
+	i := 0
+	if marker != nil {
+		i, _ = strconv.Atoi(*marker)
+	}
+
+	if i >= n {
+		return -1, nil
+	}
+
+	if i+1 < n {
+		return i, aws.String(strconv.Itoa(i + 1))
+	}
+
+	return i, nil
+}
+
+type fakeEFS struct {
+	mountTargets map[string][]string
+}
+
+func (f fakeEFS) DescribeMountTargets(_ context.Context, params *efs.DescribeMountTargetsInput,
+	_ ...func(*efs.Options)) (*efs.DescribeMountTargetsOutput, error) {
+	var result efs.DescribeMountTargetsOutput
+
+	for _, id := range f.mountTargets[*params.FileSystemId] {
+		result.MountTargets = append(result.MountTargets, efsTypes.MountTargetDescription{MountTargetId: aws.String(id)})
+	}
+
+	return &result, nil
+}
+
+func TestExpanders_IAMUser(t *testing.T) {
+	clients := resource.ExpanderClients{
+		IAM: fakeIAM{
+			attachedPolicies: map[string][]string{
+				"alice": {"arn:aws:iam::aws:policy/ReadOnlyAccess", "arn:aws:iam::aws:policy/IAMUserChangePassword"},
+				"bob":   {},
+			},
+			inlinePolicies: map[string][]string{
+				"alice": {"s3-access"},
+			},
+		},
+	}
+
+	expanders := resource.Expanders("aws_iam_user")
+	require.Len(t, expanders, 2)
+
+	users := []terraform.Resource{
+		{Type: "aws_iam_user", ID: "alice"},
+		{Type: "aws_iam_user", ID: "bob"},
+	}
+
+	assert.Equal(t, "aws_iam_user_policy_attachment", expanders[0].ChildType)
+
+	children, errs := expandEach(expanders[0], clients, users)
+	assert.Empty(t, errs)
+	assert.Equal(t, []resource.Child{
+		{
+			Type: "aws_iam_user_policy_attachment",
+			ID:   "arn:aws:iam::aws:policy/ReadOnlyAccess",
+			Attributes: map[string]cty.Value{
+				"user":       cty.StringVal("alice"),
+				"policy_arn": cty.StringVal("arn:aws:iam::aws:policy/ReadOnlyAccess"),
+			},
+		},
+		{
+			Type: "aws_iam_user_policy_attachment",
+			ID:   "arn:aws:iam::aws:policy/IAMUserChangePassword",
+			Attributes: map[string]cty.Value{
+				"user":       cty.StringVal("alice"),
+				"policy_arn": cty.StringVal("arn:aws:iam::aws:policy/IAMUserChangePassword"),
+			},
+		},
+	}, children)
+
+	assert.Equal(t, "aws_iam_user_policy", expanders[1].ChildType)
+
+	children, errs = expandEach(expanders[1], clients, users)
+	assert.Empty(t, errs)
+	assert.Equal(t, []resource.Child{
+		{Type: "aws_iam_user_policy", ID: "alice:s3-access"},
+	}, children)
+
+	children, errs = expanders[0].Expand(context.Background(), clients,
+		terraform.Resource{Type: "aws_iam_user", ID: "deleted"})
+	assert.Empty(t, children)
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "NoSuchEntity")
+}
+
+func TestExpanders_EFSFileSystem(t *testing.T) {
+	clients := resource.ExpanderClients{
+		EFS: fakeEFS{
+			mountTargets: map[string][]string{
+				"fs-1": {"fsmt-1", "fsmt-2"},
+			},
+		},
+	}
+
+	expanders := resource.Expanders("aws_efs_file_system")
+	require.Len(t, expanders, 1)
+	assert.Equal(t, "aws_efs_mount_target", expanders[0].ChildType)
+
+	children, errs := expandEach(expanders[0], clients, []terraform.Resource{
+		{Type: "aws_efs_file_system", ID: "fs-1"},
+		{Type: "aws_efs_file_system", ID: "fs-2"},
+	})
+	assert.Empty(t, errs)
+	assert.Equal(t, []resource.Child{
+		{Type: "aws_efs_mount_target", ID: "fsmt-1"},
+		{Type: "aws_efs_mount_target", ID: "fsmt-2"},
+	}, children)
+}
+
+func TestExpanders_IAMRole(t *testing.T) {
+	clients := resource.ExpanderClients{
+		IAM: fakeIAM{
+			attachedPolicies: map[string][]string{
+				"ci": {"arn:aws:iam::aws:policy/ReadOnlyAccess"},
+			},
+			inlinePolicies: map[string][]string{
+				"ci": {"s3-access", "ecr-access"},
+			},
+			instanceProfiles: map[string][]string{
+				"ci": {"ci-runner"},
+			},
+			removedRoles: map[string]string{},
+		},
+	}
+
+	roles := []terraform.Resource{{Type: "aws_iam_role", ID: "ci"}}
+
+	children := expand(t, clients, "aws_iam_role", roles)
+
+	// the role is removed from its instance profile, which itself is not deleted
+	links := children["aws_iam_role_instance_profile"]
+	delete(children, "aws_iam_role_instance_profile")
+
+	require.Len(t, links, 1)
+	assert.Equal(t, "ci:ci-runner", links[0].ID)
+	require.NotNil(t, links[0].Resource)
+
+	require.NoError(t, links[0].Resource.Destroy())
+	assert.Equal(t, map[string]string{"ci-runner": "ci"}, clients.IAM.(fakeIAM).removedRoles)
+
+	// a role that has already been removed is not an error
+	require.NoError(t, links[0].Resource.Destroy())
+
+	assert.Equal(t, map[string][]resource.Child{
+		"aws_iam_role_policy_attachment": {
+			{
+				Type: "aws_iam_role_policy_attachment",
+				ID:   "arn:aws:iam::aws:policy/ReadOnlyAccess",
+				Attributes: map[string]cty.Value{
+					"role":       cty.StringVal("ci"),
+					"policy_arn": cty.StringVal("arn:aws:iam::aws:policy/ReadOnlyAccess"),
+				},
+			},
+		},
+		"aws_iam_role_policy": {
+			{Type: "aws_iam_role_policy", ID: "ci:s3-access"},
+			{Type: "aws_iam_role_policy", ID: "ci:ecr-access"},
+		},
+	}, children)
+}
+
+func TestExpanders_IAMGroup(t *testing.T) {
+	clients := resource.ExpanderClients{
+		IAM: fakeIAM{
+			attachedPolicies: map[string][]string{
+				"developers": {"arn:aws:iam::aws:policy/PowerUserAccess"},
+				"empty":      {},
+			},
+			inlinePolicies: map[string][]string{
+				"developers": {"deny-billing"},
+			},
+			groupUsers: map[string][]string{
+				"developers": {"alice", "bob"},
+			},
+		},
+	}
+
+	groups := []terraform.Resource{
+		{Type: "aws_iam_group", ID: "developers"},
+		{Type: "aws_iam_group", ID: "empty"},
+	}
+
+	children := expand(t, clients, "aws_iam_group", groups)
+
+	assert.Equal(t, map[string][]resource.Child{
+		"aws_iam_group_membership": {
+			{
+				Type: "aws_iam_group_membership",
+				ID:   "developers",
+				Attributes: map[string]cty.Value{
+					"name":  cty.StringVal("developers"),
+					"group": cty.StringVal("developers"),
+				},
+			},
+		},
+		"aws_iam_group_policy_attachment": {
+			{
+				Type: "aws_iam_group_policy_attachment",
+				ID:   "arn:aws:iam::aws:policy/PowerUserAccess",
+				Attributes: map[string]cty.Value{
+					"group":      cty.StringVal("developers"),
+					"policy_arn": cty.StringVal("arn:aws:iam::aws:policy/PowerUserAccess"),
+				},
+			},
+		},
+		"aws_iam_group_policy": {
+			{Type: "aws_iam_group_policy", ID: "developers:deny-billing"},
+		},
+	}, children)
+}
+
+// expandEach runs an expander for each of the given parents, like resources are expanded when listed.
+func expandEach(e resource.Expander, clients resource.ExpanderClients,
+	parents []terraform.Resource) ([]resource.Child, []error) {
+	var result []resource.Child
+	var errs []error
+
+	for _, parent := range parents {
+		children, parentErrs := e.Expand(context.Background(), clients, parent)
+
+		result = append(result, children...)
+		errs = append(errs, parentErrs...)
+	}
+
+	return result, errs
+}
+
+// expand runs all expanders of a parent type and returns the children by their type.
+func expand(t *testing.T, clients resource.ExpanderClients, parentType string,
+	parents []terraform.Resource) map[string][]resource.Child {
+	result := map[string][]resource.Child{}
+
+	for _, e := range resource.Expanders(parentType) {
+		children, errs := expandEach(e, clients, parents)
+		require.Empty(t, errs)
+
+		for _, c := range children {
+			assert.Equal(t, e.ChildType, c.Type)
+		}
+
+		if len(children) > 0 {
+			result[e.ChildType] = children
+		}
+	}
+
+	return result
+}
+
+// fakeECR returns the images of repositories, where a page of results contains one image.
+type fakeECR struct {
+	images map[string][]ecrTypes.ImageDetail
+	// deleted are the digests of the deleted images per request
+	deleted [][]string
+}
+
+func (f *fakeECR) DescribeImages(_ context.Context, params *ecr.DescribeImagesInput,
+	_ ...func(*ecr.Options)) (*ecr.DescribeImagesOutput, error) {
+	images := f.images[*params.RepositoryName]
+
+	i, next := page(params.NextToken, len(images))
+	if i < 0 {
+		return &ecr.DescribeImagesOutput{}, nil
+	}
+
+	return &ecr.DescribeImagesOutput{ImageDetails: images[i : i+1], NextToken: next}, nil
+}
+
+func (f *fakeECR) BatchDeleteImage(_ context.Context, params *ecr.BatchDeleteImageInput,
+	_ ...func(*ecr.Options)) (*ecr.BatchDeleteImageOutput, error) {
+	var deleted []string
+	for _, id := range params.ImageIds {
+		deleted = append(deleted, *id.ImageDigest)
+	}
+
+	f.deleted = append(f.deleted, deleted)
+
+	return &ecr.BatchDeleteImageOutput{ImageIds: params.ImageIds}, nil
+}
+
+// fakeRoute53 returns the record sets of hosted zones, where a page of results contains one record set.
+type fakeRoute53 struct {
+	records map[string][]route53Types.ResourceRecordSet
+}
+
+func (f fakeRoute53) ListResourceRecordSets(_ context.Context, params *route53.ListResourceRecordSetsInput,
+	_ ...func(*route53.Options)) (*route53.ListResourceRecordSetsOutput, error) {
+	records := f.records[*params.HostedZoneId]
+
+	i, next := page(params.StartRecordName, len(records))
+	if i < 0 {
+		return &route53.ListResourceRecordSetsOutput{}, nil
+	}
+
+	return &route53.ListResourceRecordSetsOutput{
+		ResourceRecordSets: records[i : i+1],
+		IsTruncated:        next != nil,
+		NextRecordName:     next,
+	}, nil
+}
+
+func TestExpanders_ECRRepository(t *testing.T) {
+	var digests []string
+	var images []ecrTypes.ImageDetail
+
+	for i := 0; i < 150; i++ {
+		digest := fmt.Sprintf("sha256:%04d", i)
+
+		digests = append(digests, digest)
+		images = append(images, ecrTypes.ImageDetail{ImageDigest: aws.String(digest)})
+	}
+
+	client := &fakeECR{
+		images: map[string][]ecrTypes.ImageDetail{
+			"app": images,
+		},
+	}
+
+	children := expand(t, resource.ExpanderClients{ECR: client}, "aws_ecr_repository", []terraform.Resource{
+		{Type: "aws_ecr_repository", ID: "app"},
+		{Type: "aws_ecr_repository", ID: "empty"},
+	})
+
+	// all images of a repository are one resource
+	require.Len(t, children["aws_ecr_images"], 1)
+	child := children["aws_ecr_images"][0]
+	assert.Equal(t, "app/*", child.ID)
+
+	state := child.Resource.State()
+	require.NotNil(t, state)
+	assert.Equal(t, cty.StringVal("app"), state.GetAttr("repository_name"))
+	assert.Equal(t, 150, state.GetAttr("image_digests").LengthInt())
+
+	// images are deleted via the ECR API in batches
+	require.NoError(t, child.Resource.Destroy())
+	require.Len(t, client.deleted, 2)
+	assert.Equal(t, digests[:100], client.deleted[0])
+	assert.Equal(t, digests[100:], client.deleted[1])
+
+	// images cannot be part of a plan, since they are not Terraform resources
+	r := terraform.Resource{Type: child.Type, ID: child.ID, UpdatableResource: child.Resource}
+
+	_, err := resource.NewPlan([]terraform.Resource{r}, "v3.42.0")
+	assert.EqualError(t, err, "1 resources are deleted via the AWS API and cannot be part of a plan "+
+		"(e.g., type=aws_ecr_images, id=app/*)")
+}
+
+func TestExpanders_Route53Zone(t *testing.T) {
+	client := fakeRoute53{
+		records: map[string][]route53Types.ResourceRecordSet{
+			"Z1234": {
+				{Name: aws.String("example.com."), Type: route53Types.RRTypeNs},
+				{Name: aws.String("example.com."), Type: route53Types.RRTypeSoa},
+				{Name: aws.String("example.com."), Type: route53Types.RRTypeMx},
+				{Name: aws.String(`\052.example.com.`), Type: route53Types.RRTypeCname},
+				{Name: aws.String(`\052.a\100b\303\244.example.com.`), Type: route53Types.RRTypeTxt},
+				{Name: aws.String("sub.example.com."), Type: route53Types.RRTypeNs},
+				{Name: aws.String("www.example.com."), Type: route53Types.RRTypeA, SetIdentifier: aws.String("eu")},
+			},
+		},
+	}
+
+	children := expand(t, resource.ExpanderClients{Route53: client}, "aws_route53_zone", []terraform.Resource{
+		{Type: "aws_route53_zone", ID: "/hostedzone/Z1234"},
+	})
+
+	assert.Equal(t, map[string][]resource.Child{
+		"aws_route53_record": {
+			{
+				Type: "aws_route53_record",
+				ID:   "Z1234_example.com_MX",
+				Attributes: map[string]cty.Value{
+					"zone_id": cty.StringVal("Z1234"),
+					"name":    cty.StringVal("example.com"),
+					"type":    cty.StringVal("MX"),
+				},
+			},
+			{
+				Type: "aws_route53_record",
+				ID:   "Z1234_*.example.com_CNAME",
+				Attributes: map[string]cty.Value{
+					"zone_id": cty.StringVal("Z1234"),
+					"name":    cty.StringVal("*.example.com"),
+					"type":    cty.StringVal("CNAME"),
+				},
+			},
+			{
+				Type: "aws_route53_record",
+				ID:   "Z1234_*.a@bä.example.com_TXT",
+				Attributes: map[string]cty.Value{
+					"zone_id": cty.StringVal("Z1234"),
+					"name":    cty.StringVal("*.a@bä.example.com"),
+					"type":    cty.StringVal("TXT"),
+				},
+			},
+			{
+				Type: "aws_route53_record",
+				ID:   "Z1234_sub.example.com_NS",
+				Attributes: map[string]cty.Value{
+					"zone_id": cty.StringVal("Z1234"),
+					"name":    cty.StringVal("sub.example.com"),
+					"type":    cty.StringVal("NS"),
+				},
+			},
+			{
+				Type: "aws_route53_record",
+				ID:   "Z1234_www.example.com_A_eu",
+				Attributes: map[string]cty.Value{
+					"zone_id":        cty.StringVal("Z1234"),
+					"name":           cty.StringVal("www.example.com"),
+					"type":           cty.StringVal("A"),
+					"set_identifier": cty.StringVal("eu"),
+				},
+			},
+		},
+	}, children)
+}
@@ -0,0 +1,61 @@
+package resource
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/jckuester/awstools-lib/terraform"
+)
+
+// ExpiryFilter matches resources whose expiry, given by the value of a tag, has passed. The value is either
+// a timestamp (e.g., 2026-10-20T12:00:00Z or 2026-10-20) or an age relative to the creation time of
+// a resource (e.g., 72h, 3d or 2w), using the same formats as the created filter.
+type ExpiryFilter struct {
+	// Tag is the key of the tag that holds the expiry.
+	Tag string
+}
+
+// ExpiresAt returns the time a resource expires at according to the value of the expiry tag. It returns false
+// if the resource has no such tag, its value cannot be parsed, or if the value is an age but the creation
+// time of the resource is unknown.
+func (f ExpiryFilter) ExpiresAt(r terraform.Resource) (time.Time, bool) {
+	value, ok := r.Tags[f.Tag]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	if a, ok := parseAge(value); ok {
+		if r.CreatedAt == nil {
+			return time.Time{}, false
+		}
+
+		return a.after(*r.CreatedAt), true
+	}
+
+	if t, ok := parseTimestamp(value); ok {
+		return t, true
+	}
+
+	log.WithFields(log.Fields{
+		"type": r.Type,
+		"id":   r.ID,
+		"tag":  fmt.Sprintf("%s=%s", f.Tag, value),
+	}).Debug("failed to parse expiry")
+
+	return time.Time{}, false
+}
+
+// matchExpired checks whether the expiry of a resource has passed.
+func (f TypeFilter) matchExpired(r terraform.Resource, now time.Time) bool {
+	if f.Expired == nil {
+		return true
+	}
+
+	expiresAt, ok := f.Expired.ExpiresAt(r)
+	if !ok {
+		return false
+	}
+
+	return expiresAt.Before(now)
+}
@@ -1,30 +1,46 @@
 package resource
 
 import (
-	"errors"
 	"fmt"
-	"io/ioutil"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/apex/log"
 	"github.com/jckuester/awsls/resource"
 	"github.com/jckuester/awstools-lib/terraform"
-	"gopkg.in/yaml.v2"
+	"gopkg.in/yaml.v3"
 )
 
 // Filter represents the content of a yaml file that is used to filter resources for deletion.
 type Filter map[string][]TypeFilter
 
 // TypeFilter represents an entry in the yaml file to filter the resources of a particular resource type.
+//
+// All criteria of an entry must match. Criteria can be composed further via nested all, any and not blocks.
 type TypeFilter struct {
-	ID      *StringFilter           `yaml:",omitempty"`
-	Tagged  *bool                   `yaml:",omitempty"`
-	Tags    map[string]StringFilter `yaml:",omitempty"`
-	Created *Created                `yaml:",omitempty"`
+	ID         *StringFilter              `yaml:",omitempty"`
+	Tagged     *bool                      `yaml:",omitempty"`
+	Tags       map[string]StringFilter    `yaml:",omitempty"`
+	Created    *Created                   `yaml:",omitempty"`
+	Attributes map[string]AttributeFilter `yaml:",omitempty"`
+	// Expired matches if the expiry given by a tag has passed.
+	Expired *ExpiryFilter `yaml:",omitempty"`
+	// Account matches the ID or the name (i.e., the profile or the name of an organization's member account)
+	// of the account a resource belongs to.
+	Account *StringFilter `yaml:",omitempty"`
+	// All matches if all of the nested filters match.
+	All []TypeFilter `yaml:",omitempty"`
+	// Any matches if at least one of the nested filters matches.
+	Any []TypeFilter `yaml:",omitempty"`
+	// Not matches if the nested filter doesn't match.
+	Not *TypeFilter `yaml:",omitempty"`
+	// IncludeCloudFormation allows the entry to match resources that are owned by a CloudFormation stack,
+	// which are skipped by default. It can only be set at the top level of an entry.
+	IncludeCloudFormation bool `yaml:"include_cloudformation,omitempty"`
 }
 
 type StringMatcher interface {
@@ -36,6 +52,21 @@ type StringFilter struct {
 	Negate  bool
 }
 
+// AttributeFilter filters the value of a resource's Terraform state attribute either by a regex or,
+// if an operator is set, by a numeric comparison (e.g. "> 100").
+type AttributeFilter struct {
+	StringFilter
+	Operator string `yaml:",omitempty"`
+	Value    float64
+}
+
+// comparisonExpr matches numeric comparisons, such as "> 100" or "<= 8".
+var comparisonExpr = regexp.MustCompile(`^\s*(<=|>=|==|!=|<|>)\s*(-?[0-9]+(\.[0-9]+)?)\s*$`)
+
+// regexCache holds the compiled regular expressions of string filters by their pattern,
+// so that a pattern is compiled only once instead of for every resource it is matched against.
+var regexCache sync.Map
+
 type CreatedTime struct {
 	time.Time `yaml:",omitempty"`
 }
@@ -46,23 +77,18 @@ type Created struct {
 }
 
 // NewFilter creates a resource filter defined via a given path to a yaml file.
+// Top-level options in the yaml file (see Options) are ignored.
 func NewFilter(path string) (*Filter, error) {
-	var cfg Filter
-
-	data, err := ioutil.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-
-	err = yaml.UnmarshalStrict(data, &cfg)
+	cfg, err := NewConfig(path)
 	if err != nil {
 		return nil, err
 	}
 
-	return &cfg, nil
+	return &cfg.Filter, nil
 }
 
-// Validate checks if all resource types appearing in the config are currently supported.
+// Validate checks if all resource types appearing in the config are currently supported
+// and that all regular expressions of the filter entries compile.
 func (f Filter) Validate() error {
 	for _, rType := range f.Types() {
 		if !resource.IsSupportedType(rType) {
@@ -73,7 +99,163 @@ func (f Filter) Validate() error {
 		if rType == "aws_kms_alias" {
 			return fmt.Errorf("unsupported resource type: %s", rType)
 		}
+
+		for i, tf := range f[rType] {
+			err := tf.validate()
+			if err != nil {
+				return fmt.Errorf("invalid filter entry %d of %s: %s", i+1, rType, err)
+			}
+		}
+	}
+	return nil
+}
+
+// UnsupportedCriterion is a criterion of a filter entry that resources of the type don't support
+// (i.e., tags or creation date), so that the filter entry never matches.
+type UnsupportedCriterion struct {
+	Type string
+	// Entry is the number of the filter entry of the type (starting at 1).
+	Entry     int
+	Criterion string
+}
+
+func (c UnsupportedCriterion) Error() string {
+	return fmt.Sprintf("filter entry %d of %s never matches: %s cannot be filtered by %s",
+		c.Entry, c.Type, c.Type, c.Criterion)
+}
+
+// Unsupported returns the criteria of all filter entries that resources of the type don't support.
+func (f Filter) Unsupported() []UnsupportedCriterion {
+	var result []UnsupportedCriterion
+
+	for _, rType := range f.Types() {
+		for i, tf := range f[rType] {
+			var c criteria
+			tf.addCriteria(&c)
+
+			if c.tags && !resource.SupportsTags(rType) {
+				result = append(result, UnsupportedCriterion{Type: rType, Entry: i + 1, Criterion: "tags"})
+			}
+
+			if c.created && !SupportsCreationDate(rType) {
+				result = append(result, UnsupportedCriterion{Type: rType, Entry: i + 1, Criterion: "created"})
+			}
+		}
+	}
+
+	return result
+}
+
+// criteria are the criteria that filter entries use, which depend on information that not every resource has.
+type criteria struct {
+	tags    bool
+	created bool
+}
+
+// criteria returns the criteria used by any of the filter entries of a resource type.
+func (f Filter) criteria(rType string) criteria {
+	var c criteria
+
+	for _, tf := range f[rType] {
+		tf.addCriteria(&c)
+	}
+
+	return c
+}
+
+// addCriteria adds the criteria used by the type filter, including its nested filters.
+func (f TypeFilter) addCriteria(c *criteria) {
+	if f.Tagged != nil || len(f.Tags) > 0 || f.Expired != nil {
+		c.tags = true
+	}
+
+	if f.Created != nil {
+		c.created = true
+	}
+
+	for _, tf := range f.All {
+		tf.addCriteria(c)
+	}
+
+	for _, tf := range f.Any {
+		tf.addCriteria(c)
+	}
+
+	if f.Not != nil {
+		f.Not.addCriteria(c)
+	}
+}
+
+// validate checks that all regular expressions of the type filter, including its nested filters, compile.
+func (f TypeFilter) validate() error {
+	if f.ID != nil {
+		if err := f.ID.validate(); err != nil {
+			return fmt.Errorf("id: %s", err)
+		}
+	}
+
+	if f.Account != nil {
+		if err := f.Account.validate(); err != nil {
+			return fmt.Errorf("account: %s", err)
+		}
+	}
+
+	if err := validateTagFilters(f.Tags); err != nil {
+		return err
+	}
+
+	if f.Expired != nil && f.Expired.Tag == "" {
+		return fmt.Errorf("expired: tag must be set")
+	}
+
+	for path, attrFilter := range f.Attributes {
+		if attrFilter.Operator != "" {
+			continue
+		}
+
+		if err := attrFilter.StringFilter.validate(); err != nil {
+			return fmt.Errorf("attribute %s: %s", path, err)
+		}
+	}
+
+	for _, tf := range f.All {
+		if err := tf.validateNested(); err != nil {
+			return fmt.Errorf("all: %s", err)
+		}
+	}
+
+	for _, tf := range f.Any {
+		if err := tf.validateNested(); err != nil {
+			return fmt.Errorf("any: %s", err)
+		}
+	}
+
+	if f.Not != nil {
+		if err := f.Not.validateNested(); err != nil {
+			return fmt.Errorf("not: %s", err)
+		}
 	}
+
+	return nil
+}
+
+// validateNested validates a nested filter of an all, any or not block.
+func (f TypeFilter) validateNested() error {
+	if f.IncludeCloudFormation {
+		return fmt.Errorf("include_cloudformation can only be set at the top level of a filter entry")
+	}
+
+	return f.validate()
+}
+
+// validateTagFilters checks that the regular expressions of all tag values compile.
+func validateTagFilters(tags map[string]StringFilter) error {
+	for key, valueFilter := range tags {
+		if err := valueFilter.validate(); err != nil {
+			return fmt.Errorf("tag %s: %s", key, err)
+		}
+	}
+
 	return nil
 }
 
@@ -86,6 +268,10 @@ func (f Filter) Types() []string {
 	}
 
 	sort.Slice(resTypes, func(i, j int) bool {
+		if DependencyOrder[resTypes[i]] == DependencyOrder[resTypes[j]] {
+			return resTypes[i] < resTypes[j]
+		}
+
 		return DependencyOrder[resTypes[i]] > DependencyOrder[resTypes[j]]
 	})
 
@@ -98,14 +284,40 @@ func (f TypeFilter) matchID(id string) bool {
 		return true
 	}
 
-	if ok, err := f.ID.matches(id); ok {
+	match, err := f.ID.matches(id)
+	if err != nil {
+		log.WithError(err).Error("failed to match ID")
+		return false
+	}
+
+	return match
+}
+
+// matchAccount checks whether the ID, profile or name of the account a resource belongs to matches the filter.
+func (f TypeFilter) matchAccount(r terraform.Resource, names AccountNames) bool {
+	if f.Account == nil {
+		return true
+	}
+
+	accountFilter := StringFilter{Pattern: f.Account.Pattern}
+
+	for _, s := range []string{r.AccountID, r.Profile, names[r.AccountID]} {
+		if s == "" {
+			continue
+		}
+
+		match, err := accountFilter.matches(s)
 		if err != nil {
-			log.WithError(err).Fatal("failed to match ID")
+			log.WithError(err).Error("failed to match account")
+			return false
+		}
+
+		if match {
+			return !f.Account.Negate
 		}
-		return true
 	}
 
-	return false
+	return f.Account.Negate
 }
 
 // MatchTagged filters resources with a non-empty or empty tag set.
@@ -144,11 +356,13 @@ func (f TypeFilter) matchIncludedTags(tags map[string]string) bool {
 			return false
 		}
 
-		if match, err := valueFilter.matches(value); !match {
-			if err != nil {
-				log.WithError(err).Fatal("failed to match tags")
-			}
+		match, err := valueFilter.matches(value)
+		if err != nil {
+			log.WithError(err).Error("failed to match tags")
+			return false
+		}
 
+		if !match {
 			return false
 		}
 	}
@@ -170,11 +384,13 @@ func (f TypeFilter) matchExcludedTags(tags map[string]string) bool {
 			return true
 		}
 
-		if match, err := valueFilter.matches(value); !match {
-			if err != nil {
-				log.WithError(err).Fatal("failed to match tags")
-			}
+		match, err := valueFilter.matches(value)
+		if err != nil {
+			log.WithError(err).Error("failed to match tags")
+			return false
+		}
 
+		if !match {
 			return true
 		}
 	}
@@ -237,9 +453,47 @@ func (f TypeFilter) matchCreated(creationTime *time.Time) bool {
 	return createdAfter && createdBefore
 }
 
+// matchAttributes checks whether all attributes of a resource's Terraform state match the filter.
+func (f TypeFilter) matchAttributes(r terraform.Resource) bool {
+	for path, attrFilter := range f.Attributes {
+		value, err := GetAttribute(&r, path)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"type":      r.Type,
+				"id":        r.ID,
+				"attribute": path,
+			}).WithError(err).Debug("failed to get attribute")
+
+			return false
+		}
+
+		match, err := attrFilter.matches(value)
+		if err != nil {
+			log.WithError(err).Error("failed to match attribute")
+			return false
+		}
+
+		if !match {
+			return false
+		}
+	}
+
+	return true
+}
+
 // Match checks whether a resource matches the filter criteria.
 func (f Filter) Match(r terraform.Resource) bool {
-	resTypeFilters, found := f[r.Type]
+	return f.match(r, false, nil)
+}
+
+// skipsStackOwned returns true if resources of the type that are owned by a CloudFormation stack are skipped
+// by any entry of the type, i.e., not every entry sets include_cloudformation.
+func (f Filter) skipsStackOwned(rType string) bool {
+	if rType == "aws_cloudformation_stack" {
+		return false
+	}
+
+	resTypeFilters, found := f[rType]
 	if !found {
 		return false
 	}
@@ -249,10 +503,36 @@ func (f Filter) Match(r terraform.Resource) bool {
 	}
 
 	for _, rtf := range resTypeFilters {
-		if rtf.MatchTagged(r.Tags) &&
-			rtf.MatchTags(r.Tags) &&
-			rtf.matchID(r.ID) &&
-			rtf.matchCreated(r.CreatedAt) {
+		if !rtf.IncludeCloudFormation {
+			return true
+		}
+	}
+
+	return false
+}
+
+// match checks whether a resource matches any entry of its type, where accounts are matched by name as well.
+// Resources owned by a CloudFormation stack only match entries that set include_cloudformation, unless
+// includeStackOwned is true.
+func (f Filter) match(r terraform.Resource, includeStackOwned bool, names AccountNames) bool {
+	resTypeFilters, found := f[r.Type]
+	if !found {
+		return false
+	}
+
+	_, stackOwned := StackName(r)
+	stackOwned = stackOwned && !includeStackOwned
+
+	if len(resTypeFilters) == 0 {
+		return !stackOwned
+	}
+
+	for _, rtf := range resTypeFilters {
+		if stackOwned && !rtf.IncludeCloudFormation {
+			continue
+		}
+
+		if rtf.match(r, names) {
 			return true
 		}
 	}
@@ -260,22 +540,145 @@ func (f Filter) Match(r terraform.Resource) bool {
 	return false
 }
 
+// Match checks whether a resource matches all criteria of the type filter,
+// including its nested all, any and not blocks.
+func (f TypeFilter) Match(r terraform.Resource) bool {
+	return f.match(r, nil)
+}
+
+func (f TypeFilter) match(r terraform.Resource, names AccountNames) bool {
+	if !(f.MatchTagged(r.Tags) &&
+		f.MatchTags(r.Tags) &&
+		f.matchID(r.ID) &&
+		f.matchAccount(r, names) &&
+		f.matchCreated(r.CreatedAt) &&
+		f.matchExpired(r, time.Now()) &&
+		f.matchAttributes(r)) {
+		return false
+	}
+
+	for _, tf := range f.All {
+		if !tf.match(r, names) {
+			return false
+		}
+	}
+
+	if len(f.Any) > 0 {
+		matchAny := false
+
+		for _, tf := range f.Any {
+			if tf.match(r, names) {
+				matchAny = true
+				break
+			}
+		}
+
+		if !matchAny {
+			return false
+		}
+	}
+
+	if f.Not != nil && f.Not.match(r, names) {
+		return false
+	}
+
+	return true
+}
+
+// validate checks that the pattern of the filter is a valid regular expression.
+func (f *StringFilter) validate() error {
+	_, err := compileRegex(f.Pattern)
+	if err != nil {
+		return fmt.Errorf("invalid regex: %s", err)
+	}
+
+	return nil
+}
+
 func (f *StringFilter) matches(s string) (bool, error) {
-	ok, err := regexp.MatchString(f.Pattern, s)
+	re, err := compileRegex(f.Pattern)
 	if err != nil {
 		return false, err
 	}
 
+	ok := re.MatchString(s)
+
 	if f.Negate {
 		return !ok, nil
 	}
 
-	return ok, err
+	return ok, nil
+}
+
+// compileRegex returns the compiled regular expression of a pattern, which is compiled only once.
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	if re, ok := regexCache.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	regexCache.Store(pattern, re)
+
+	return re, nil
+}
+
+func (f *AttributeFilter) matches(s string) (bool, error) {
+	if f.Operator == "" {
+		return f.StringFilter.matches(s)
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return false, nil
+	}
+
+	switch f.Operator {
+	case "<":
+		return v < f.Value, nil
+	case "<=":
+		return v <= f.Value, nil
+	case ">":
+		return v > f.Value, nil
+	case ">=":
+		return v >= f.Value, nil
+	case "==":
+		return v == f.Value, nil
+	case "!=":
+		return v != f.Value, nil
+	default:
+		return false, fmt.Errorf("unsupported operator: %s", f.Operator)
+	}
 }
 
-func (f *StringFilter) UnmarshalYAML(unmarshal func(interface{}) error) error {
+func (f *AttributeFilter) UnmarshalYAML(value *yaml.Node) error {
 	var v string
-	if err := unmarshal(&v); err != nil {
+	if err := value.Decode(&v); err != nil {
+		return err
+	}
+
+	if m := comparisonExpr.FindStringSubmatch(v); m != nil {
+		value, err := strconv.ParseFloat(m[2], 64)
+		if err != nil {
+			return err
+		}
+
+		*f = AttributeFilter{Operator: m[1], Value: value}
+
+		return nil
+	}
+
+	return f.StringFilter.UnmarshalYAML(value)
+}
+
+// UnmarshalYAML reads a pattern, which is negated if surrounded by NOT(...), and compiles it.
+// An invalid pattern is reported with its line number.
+func (f *StringFilter) UnmarshalYAML(value *yaml.Node) error {
+	var v string
+	if err := value.Decode(&v); err != nil {
 		return err
 	}
 	if strings.HasPrefix(v, "NOT(") && strings.HasSuffix(v, ")") {
@@ -283,59 +686,93 @@ func (f *StringFilter) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	} else {
 		*f = StringFilter{v, false}
 	}
+
+	if _, err := compileRegex(f.Pattern); err != nil {
+		return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: invalid regex %q: %s", value.Line, v, err)}}
+	}
 	return nil
 }
 
-func (c *CreatedTime) UnmarshalYAML(unmarshal func(interface{}) error) error {
+func (c *CreatedTime) UnmarshalYAML(value *yaml.Node) error {
 	var v interface{}
-	if err := unmarshal(&v); err != nil {
+	if err := value.Decode(&v); err != nil {
 		return err
 	}
-	switch value := v.(type) {
+	switch v := v.(type) {
 	case time.Time:
-		t, _ := v.(time.Time)
-		*c = CreatedTime{t}
+		*c = CreatedTime{v}
 		return nil
 	case string:
-		d, err := time.ParseDuration(value)
-		if err == nil {
-			*c = CreatedTime{time.Now().UTC().Add(-d)}
+		if a, ok := parseAge(v); ok {
+			*c = CreatedTime{a.before(time.Now().UTC())}
 			return nil
 		}
-		var t time.Time
-		err = yaml.Unmarshal([]byte("!!timestamp "+value), &t)
-		if err == nil {
+		if t, ok := parseTimestamp(v); ok {
 			*c = CreatedTime{t}
 			return nil
 		}
-		if strings.HasSuffix(value, "d") {
-			d, err := strconv.ParseInt(value[0:len(value)-1], 10, 32)
-			if err == nil {
-				*c = CreatedTime{time.Now().UTC().AddDate(0, 0, -int(d))}
-				return nil
-			}
-		}
-		if strings.HasSuffix(value, "w") {
-			w, err := strconv.ParseInt(value[0:len(value)-1], 10, 32)
-			if err == nil {
-				*c = CreatedTime{time.Now().UTC().AddDate(0, 0, -int(w*7))}
-				return nil
-			}
-		}
-		if strings.HasSuffix(value, "M") {
-			m, err := strconv.ParseInt(value[0:len(value)-1], 10, 32)
-			if err == nil {
-				*c = CreatedTime{time.Now().UTC().AddDate(0, -int(m), 0)}
-				return nil
-			}
-		}
-		if strings.HasSuffix(value, "y") {
-			y, err := strconv.ParseInt(value[0:len(value)-1], 10, 32)
-			if err == nil {
-				*c = CreatedTime{time.Now().UTC().AddDate(-int(y), 0, 0)}
-				return nil
-			}
-		}
 	}
-	return errors.New("invalid created time")
+	return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d: invalid created time: %s", value.Line, value.Value)}}
+}
+
+// age is a duration given either in the format of Go (e.g., 23h) or
+// as a number of days (e.g., 5d), weeks (2w), months (3M) or years (1y).
+type age struct {
+	duration time.Duration
+	years    int
+	months   int
+	days     int
+}
+
+// parseAge parses an age and returns false if the given string is not an age.
+func parseAge(s string) (age, bool) {
+	d, err := time.ParseDuration(s)
+	if err == nil {
+		return age{duration: d}, true
+	}
+
+	if len(s) < 2 {
+		return age{}, false
+	}
+
+	n, err := strconv.ParseInt(s[0:len(s)-1], 10, 32)
+	if err != nil {
+		return age{}, false
+	}
+
+	switch s[len(s)-1] {
+	case 'd':
+		return age{days: int(n)}, true
+	case 'w':
+		return age{days: int(n * 7)}, true
+	case 'M':
+		return age{months: int(n)}, true
+	case 'y':
+		return age{years: int(n)}, true
+	}
+
+	return age{}, false
+}
+
+// before returns the time that lies the age before the given time.
+func (a age) before(t time.Time) time.Time {
+	return t.Add(-a.duration).AddDate(-a.years, -a.months, -a.days)
+}
+
+// after returns the time that lies the age after the given time.
+func (a age) after(t time.Time) time.Time {
+	return t.Add(a.duration).AddDate(a.years, a.months, a.days)
+}
+
+// parseTimestamp parses a timestamp in one of the formats of YAML (e.g., RFC3339 or date-only)
+// and returns false if the given string is not a timestamp.
+func parseTimestamp(s string) (time.Time, bool) {
+	var t time.Time
+
+	err := yaml.Unmarshal([]byte("!!timestamp "+s), &t)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
 }
@@ -9,7 +9,6 @@ import (
 	"github.com/jckuester/awsweeper/pkg/resource"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"gopkg.in/yaml.v2"
 )
 
 func TestFilter_Validate(t *testing.T) {
@@ -48,6 +47,25 @@ func TestFilter_Validate(t *testing.T) {
 				"aws_glue_job":       {},
 			},
 		},
+		{
+			name: "invalid regex of ID",
+			f: resource.Filter{
+				"aws_instance": {{ID: &resource.StringFilter{Pattern: "^foo("}}},
+			},
+			wantErr: "invalid filter entry 1 of aws_instance: id: invalid regex: " +
+				"error parsing regexp: missing closing ): `^foo(`",
+		},
+		{
+			name: "invalid regex of nested tag",
+			f: resource.Filter{
+				"aws_instance": {
+					{},
+					{Not: &resource.TypeFilter{Tags: map[string]resource.StringFilter{"foo": {Pattern: "[a-"}}}},
+				},
+			},
+			wantErr: "invalid filter entry 2 of aws_instance: not: tag foo: invalid regex: " +
+				"error parsing regexp: missing closing ]: `[a-`",
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -62,6 +80,26 @@ func TestFilter_Validate(t *testing.T) {
 	}
 }
 
+func TestFilter_Unsupported(t *testing.T) {
+	tagged := true
+
+	f := resource.Filter{
+		"aws_instance": {{Tagged: &tagged}, {Created: &resource.Created{}}},
+		"aws_vpc": {
+			{Tags: map[string]resource.StringFilter{"env": {Pattern: "dev"}}},
+			{Not: &resource.TypeFilter{Created: &resource.Created{}}},
+		},
+		"aws_iam_user_policy": {{ID: &resource.StringFilter{Pattern: "^foo"}}},
+	}
+
+	got := f.Unsupported()
+
+	assert.Equal(t, []resource.UnsupportedCriterion{
+		{Type: "aws_vpc", Entry: 2, Criterion: "created"},
+	}, got)
+	assert.EqualError(t, got[0], "filter entry 2 of aws_vpc never matches: aws_vpc cannot be filtered by created")
+}
+
 func TestFilter_Types(t *testing.T) {
 	tests := []struct {
 		name string
@@ -104,9 +142,10 @@ func Test_ParseFile(t *testing.T) {
     created:
       before: 23h`)
 
-	var cfg resource.Filter
-	err := yaml.UnmarshalStrict(input, &cfg)
+	c, err := resource.ParseConfig(input)
 	require.NoError(t, err)
+
+	cfg := c.Filter
 	require.NotNil(t, cfg["aws_instance"])
 	require.Len(t, cfg["aws_instance"], 2)
 	require.NotNil(t, cfg["aws_instance"][0].ID)
@@ -126,6 +165,65 @@ func Test_ParseFile(t *testing.T) {
 	require.Nil(t, cfg["aws_instance"][1].Created.After)
 }
 
+func Test_ParseFile_Expired(t *testing.T) {
+	input := []byte(`aws_instance:
+  - expired:
+      tag: expires-at`)
+
+	c, err := resource.ParseConfig(input)
+	require.NoError(t, err)
+	require.Len(t, c.Filter["aws_instance"], 1)
+	assert.Equal(t, &resource.ExpiryFilter{Tag: "expires-at"}, c.Filter["aws_instance"][0].Expired)
+
+	c, err = resource.ParseConfig([]byte("aws_instance:\n  - expired: {}"))
+	require.NoError(t, err)
+	assert.EqualError(t, c.Filter.Validate(), "invalid filter entry 1 of aws_instance: expired: tag must be set")
+}
+
+func Test_ParseFile_Attributes(t *testing.T) {
+	input := []byte(`aws_ebs_volume:
+  - attributes:
+      encrypted: false
+      size: '>= 100'
+      type: NOT(^gp)`)
+
+	c, err := resource.ParseConfig(input)
+	require.NoError(t, err)
+
+	cfg := c.Filter
+	require.Len(t, cfg["aws_ebs_volume"], 1)
+
+	attrs := cfg["aws_ebs_volume"][0].Attributes
+	assert.Equal(t, resource.AttributeFilter{StringFilter: resource.StringFilter{Pattern: "false"}}, attrs["encrypted"])
+	assert.Equal(t, resource.AttributeFilter{Operator: ">=", Value: 100}, attrs["size"])
+	assert.Equal(t, resource.AttributeFilter{StringFilter: resource.StringFilter{Pattern: "^gp", Negate: true}}, attrs["type"])
+}
+
+func Test_ParseFile_BooleanComposition(t *testing.T) {
+	input := []byte(`aws_instance:
+  - tags:
+      env: dev
+    any:
+      - created:
+          before: 7d
+      - not:
+          tags:
+            owner: .*`)
+
+	c, err := resource.ParseConfig(input)
+	require.NoError(t, err)
+
+	cfg := c.Filter
+	require.Len(t, cfg["aws_instance"], 1)
+
+	tf := cfg["aws_instance"][0]
+	assert.Equal(t, map[string]resource.StringFilter{"env": {Pattern: "dev"}}, tf.Tags)
+	require.Len(t, tf.Any, 2)
+	require.NotNil(t, tf.Any[0].Created)
+	require.NotNil(t, tf.Any[1].Not)
+	assert.Equal(t, map[string]resource.StringFilter{"owner": {Pattern: ".*"}}, tf.Any[1].Not.Tags)
+}
+
 func TestTypeFilter_MatchTagged(t *testing.T) {
 	tests := []struct {
 		name   string
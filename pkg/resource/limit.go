@@ -0,0 +1,95 @@
+package resource
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jckuester/awstools-lib/terraform"
+)
+
+// Limits are the maximum numbers of resources that may be deleted in a run.
+type Limits struct {
+	// Total is the maximum number of resources of all types (0 means unlimited).
+	Total int
+	// ByType is the maximum number of resources per type.
+	ByType map[string]int
+}
+
+// TypeCount is the number of resources of a type.
+type TypeCount struct {
+	Type  string
+	Count int
+	// Max is the limit of resources of the type (0 if there is none).
+	Max int
+}
+
+// LimitError means that more resources than allowed would be deleted.
+type LimitError struct {
+	Count int
+	// Max is the limit of resources of all types (0 if there is none).
+	Max int
+	// Exceeded are the counts of the resource types that exceed their limit or,
+	// if the total limit is exceeded, of all resource types.
+	Exceeded []TypeCount
+}
+
+func (e LimitError) Error() string {
+	var counts []string
+
+	for _, c := range e.Exceeded {
+		if c.Max > 0 {
+			counts = append(counts, fmt.Sprintf("%s: %d (max %d)", c.Type, c.Count, c.Max))
+		} else {
+			counts = append(counts, fmt.Sprintf("%s: %d", c.Type, c.Count))
+		}
+	}
+
+	if e.Max > 0 && e.Count > e.Max {
+		return fmt.Sprintf("%d resources exceed the maximum of %d deletions (%s)", e.Count, e.Max,
+			strings.Join(counts, ", "))
+	}
+
+	return fmt.Sprintf("resources exceed the maximum deletions per type (%s)", strings.Join(counts, ", "))
+}
+
+// Check returns a LimitError if the given resources exceed the total limit or the limit of any type.
+// Only resources that matched the filter count; resources of dependent types (i.e., the ones that
+// belong to a parent, such as the policy attachments of an IAM user) are deleted along with their parent.
+func (l Limits) Check(res []terraform.Resource, parents Parents) error {
+	count := 0
+	countByType := map[string]int{}
+
+	for _, r := range res {
+		if _, ok := parents.Of(r); ok {
+			continue
+		}
+
+		count++
+		countByType[r.Type]++
+	}
+
+	types := make([]string, 0, len(countByType))
+	for t := range countByType {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+
+	totalExceeded := l.Total > 0 && count > l.Total
+
+	var exceeded []TypeCount
+
+	for _, t := range types {
+		max := l.ByType[t]
+
+		if totalExceeded || (max > 0 && countByType[t] > max) {
+			exceeded = append(exceeded, TypeCount{Type: t, Count: countByType[t], Max: max})
+		}
+	}
+
+	if len(exceeded) == 0 {
+		return nil
+	}
+
+	return LimitError{Count: count, Max: l.Total, Exceeded: exceeded}
+}
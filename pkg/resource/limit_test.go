@@ -0,0 +1,81 @@
+package resource_test
+
+import (
+	"testing"
+
+	"github.com/jckuester/awstools-lib/terraform"
+	"github.com/jckuester/awsweeper/pkg/resource"
+	terradozerRes "github.com/jckuester/terradozer/pkg/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimits_Check(t *testing.T) {
+	res := []terraform.Resource{
+		{Type: "aws_instance", ID: "i-1"},
+		{Type: "aws_vpc", ID: "vpc-1"},
+		{Type: "aws_instance", ID: "i-2"},
+		{Type: "aws_instance", ID: "i-3"},
+	}
+
+	tests := []struct {
+		name    string
+		limits  resource.Limits
+		wantErr string
+	}{
+		{
+			name: "no limits",
+		},
+		{
+			name:   "within limits",
+			limits: resource.Limits{Total: 4, ByType: map[string]int{"aws_instance": 3}},
+		},
+		{
+			name:    "total limit exceeded",
+			limits:  resource.Limits{Total: 3, ByType: map[string]int{"aws_instance": 5}},
+			wantErr: "4 resources exceed the maximum of 3 deletions (aws_instance: 3 (max 5), aws_vpc: 1)",
+		},
+		{
+			name:    "limit of type exceeded",
+			limits:  resource.Limits{ByType: map[string]int{"aws_instance": 2, "aws_vpc": 1}},
+			wantErr: "resources exceed the maximum deletions per type (aws_instance: 3 (max 2))",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.limits.Check(res, nil)
+
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLimits_Check_DependentTypes(t *testing.T) {
+	user := terraform.Resource{Type: "aws_iam_user", ID: "alice"}
+
+	var res []terraform.Resource
+	parents := resource.Parents{}
+
+	res = append(res, user)
+	for _, id := range []string{"alice-1", "alice-2", "alice-3"} {
+		child := terraform.Resource{
+			Type:              "aws_iam_user_policy_attachment",
+			ID:                id,
+			UpdatableResource: terradozerRes.New("aws_iam_user_policy_attachment", id, nil, nil),
+		}
+
+		res = append(res, child)
+		parents[child.UpdatableResource] = user
+	}
+
+	limits := resource.Limits{Total: 1, ByType: map[string]int{"aws_iam_user_policy_attachment": 1}}
+
+	// resources of dependent types are deleted along with their parent and don't count
+	assert.NoError(t, limits.Check(res, parents))
+
+	assert.EqualError(t, limits.Check(res, nil),
+		"4 resources exceed the maximum of 1 deletions (aws_iam_user: 1, aws_iam_user_policy_attachment: 3 (max 1))")
+}
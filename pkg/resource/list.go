@@ -4,13 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/apex/log"
-	"github.com/aws/aws-sdk-go-v2/service/efs"
-	"github.com/aws/aws-sdk-go-v2/service/iam"
 	"github.com/fatih/color"
 	awsls "github.com/jckuester/awsls/aws"
 	awslsRes "github.com/jckuester/awsls/resource"
@@ -22,214 +20,619 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
-func List(ctx context.Context, filter *Filter, clients map[aws.ClientKey]aws.Client,
-	providers map[aws.ClientKey]provider.TerraformProvider, outputType string) []terradozerRes.DestroyableResource {
-	var destroyableRes []terradozerRes.DestroyableResource
+// ListResult is the result of listing resources.
+type ListResult struct {
+	// Resources are the resources that match the filter.
+	Resources []terraform.Resource
+	// Protected are the resources that match the filter, but are protected from deletion.
+	Protected []terraform.Resource
+	// StackOwned are the resources that would match the filter, but are skipped,
+	// since they are owned by a CloudFormation stack.
+	StackOwned []terraform.Resource
+	// Parents are the matched resources that the resources of dependent types belong to (see Expander).
+	Parents Parents
+	// Errors are the errors that occurred while listing resources, e.g. while updating their Terraform state.
+	Errors []ListError
+}
 
-	for _, rType := range filter.Types() {
-		for key, client := range clients {
-			err := client.SetAccountID(ctx)
-			if err != nil {
-				log.WithError(err).Fatal("failed to set account ID")
-				continue
-			}
+// Parents maps resources of dependent types (see Expander) to the resource they belong to.
+type Parents map[terradozerRes.UpdatableResource]terraform.Resource
 
-			resources, err := awsls.ListResourcesByType(ctx, &client, rType)
-			if err != nil {
-				log.WithError(err).Fatal("failed to list awsls supported resources")
-				continue
-			}
+// Of returns the resource that the given resource belongs to, if it's a resource of a dependent type.
+func (p Parents) Of(r terraform.Resource) (terraform.Resource, bool) {
+	if r.UpdatableResource == nil {
+		return terraform.Resource{}, false
+	}
 
-			resourcesWithStates, errs := terraform.UpdateStates(resources, providers, 10, true)
-			for _, err := range errs {
-				fmt.Fprint(os.Stderr, color.RedString("Error %s: %s\n", rType, err))
-			}
+	parent, ok := p[r.UpdatableResource]
+	if !ok {
+		return terraform.Resource{}, false
+	}
 
-			filteredRes := filter.Apply(resourcesWithStates)
-			print(filteredRes, outputType)
+	parent.Region = r.Region
+	parent.Profile = r.Profile
+	parent.AccountID = r.AccountID
 
-			p := providers[key]
+	return parent, true
+}
 
-			switch rType {
-			case "aws_iam_user":
-				attachedPolicies := getAttachedUserPolicies(ctx, filteredRes, client, &p)
-				print(attachedPolicies, outputType)
+// ListError is an error that occurred while listing resources of a type in an account and region.
+type ListError struct {
+	Profile   string
+	AccountID string
+	Region    string
+	// Type is empty if the error is not specific to a resource type (e.g., the account ID could not be retrieved).
+	Type string
+	Err  error
+}
 
-				inlinePolicies := getInlineUserPolicies(ctx, filteredRes, client, &p)
-				print(inlinePolicies, outputType)
+func (e ListError) Error() string {
+	account := AccountRegion{Profile: e.Profile, AccountID: e.AccountID, Region: e.Region}
 
-				filteredRes = append(filteredRes, attachedPolicies...)
-				filteredRes = append(filteredRes, inlinePolicies...)
-			case "aws_iam_policy":
-				policyAttachments := getPolicyAttachments(filteredRes, &p)
-				print(policyAttachments, outputType)
+	if e.Type == "" {
+		return fmt.Sprintf("%s: %s", account, e.Err)
+	}
 
-				filteredRes = append(filteredRes, policyAttachments...)
+	return fmt.Sprintf("%s, %s: %s", account, e.Type, e.Err)
+}
 
-			case "aws_efs_file_system":
-				mountTargets := getEfsMountTargets(ctx, filteredRes, client, &p)
-				print(mountTargets, outputType)
+func (e ListError) Unwrap() error {
+	return e.Err
+}
 
-				filteredRes = append(filteredRes, mountTargets...)
-			}
+// maxListsPerService limits the number of resource types of the same AWS service that are listed concurrently
+// in an account and region, so that listing doesn't run into the rate limits of the service's API.
+const maxListsPerService = 2
+
+// updateStatesParallel limits the number of concurrent updates of Terraform states per listed resource type.
+const updateStatesParallel = 10
+
+// List lists all resources that match the filter of the config and prints them grouped by account and region.
+// Resources that are protected by the config are printed as skipped and are returned separately.
+//
+// Resources of each combination of client and type are listed concurrently (see ListTypes).
+//
+// Errors don't stop listing: if the account ID of a client cannot be retrieved, the client is skipped;
+// if resources of a type cannot be listed, the type is skipped. All errors are returned as part of the result (and are not printed).
+func List(ctx context.Context, cfg *Config, clients map[aws.ClientKey]aws.Client,
+	providers map[aws.ClientKey]provider.TerraformProvider, outputType string, parallel int) ListResult {
+	stacks := &stackOwnersCache{}
+
+	lister := func(ctx context.Context, client aws.Client, rType string) TypeListResult {
+		return listType(ctx, cfg, client, rType, providers, stacks)
+	}
 
-			for _, r := range filteredRes {
-				destroyableRes = append(destroyableRes, terradozerRes.NewWithState(r.Type, r.ID, &p, r.State()))
-			}
+	return ListTypes(ctx, clients, cfg.Filter.Types(), lister, cfg.AccountNames, outputType, parallel)
+}
+
+// TypeLister lists the resources of a type via a client.
+type TypeLister func(ctx context.Context, client aws.Client, rType string) TypeListResult
+
+// ListTypes lists the resources of the given types via each client with the given lister and prints them grouped by
+// account and region. The account ID of clients is retrieved first, unless it is already set.
+//
+// Resources of each combination of client and type are listed concurrently, limited by parallel
+// (and maxListsPerService). Resources are still printed and returned in the order of the clients
+// (sorted by profile and region) and types, as if listed sequentially.
+func ListTypes(ctx context.Context, clients map[aws.ClientKey]aws.Client, types []string, lister TypeLister,
+	names AccountNames, outputType string, parallel int) ListResult {
+	var result ListResult
+
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	sem := make(chan struct{}, parallel)
+
+	keys := sortedClientKeys(clients)
+	clientsWithAccountID, accountErrs := setAccountIDs(ctx, clients, sem)
+
+	jobs := newListJobs(clientsWithAccountID, keys, accountErrs, types)
+
+	var serviceSemsLock sync.Mutex
+	serviceSems := map[string]chan struct{}{}
+
+	// serviceSem returns the semaphore limiting concurrent lists of a service within an account and region
+	serviceSem := func(key aws.ClientKey, rType string) chan struct{} {
+		serviceSemsLock.Lock()
+		defer serviceSemsLock.Unlock()
+
+		id := fmt.Sprintf("%s/%s/%s", key.Profile, key.Region, awslsRes.Services[rType])
+
+		s, ok := serviceSems[id]
+		if !ok {
+			s = make(chan struct{}, maxListsPerService)
+			serviceSems[id] = s
 		}
+
+		return s
 	}
 
-	return destroyableRes
-}
+	for _, job := range jobs {
+		if job.rType == "" {
+			close(job.done)
+			continue
+		}
 
-func getAttachedUserPolicies(ctx context.Context, users []terraform.Resource, client aws.Client,
-	provider *provider.TerraformProvider) []terraform.Resource {
-	var result []terraform.Resource
+		go func(job *listJob) {
+			defer close(job.done)
 
-	for _, user := range users {
-		pg := iam.NewListAttachedUserPoliciesPaginator(client.Iamconn, &iam.ListAttachedUserPoliciesInput{
-			UserName: &user.ID,
-		})
+			s := serviceSem(job.key, job.rType)
+			s <- struct{}{}
+			defer func() { <-s }()
 
-		for pg.HasMorePages() {
-			page, err := pg.NextPage(ctx)
-			if err != nil {
-				fmt.Fprint(os.Stderr, color.RedString("Error: %s\n", err))
-				continue
-			}
+			sem <- struct{}{}
+			defer func() { <-sem }()
 
-			for _, attachedPolicy := range page.AttachedPolicies {
-				r := terraform.Resource{
-					Type: "aws_iam_user_policy_attachment",
-					ID:   *attachedPolicy.PolicyArn,
-				}
+			job.result = lister(ctx, job.client, job.rType)
+		}(job)
+	}
 
-				r.UpdatableResource = terradozerRes.New(r.Type, r.ID, map[string]cty.Value{
-					"user":       cty.StringVal(user.ID),
-					"policy_arn": cty.StringVal(*attachedPolicy.PolicyArn),
-				}, provider)
+	var lastKey *aws.ClientKey
 
-				err := r.UpdateState()
-				if err != nil {
-					fmt.Fprint(os.Stderr, color.RedString("Error: %s\n", err))
-					continue
-				}
+	for _, job := range jobs {
+		<-job.done
+
+		result.Errors = append(result.Errors, job.result.Errors...)
 
-				result = append(result, r)
+		if job.rType == "" {
+			continue
+		}
+
+		if len(clients) > 1 && (lastKey == nil || *lastKey != job.key) {
+			printAccountRegion(AccountRegion{
+				Profile:   job.client.Profile,
+				AccountID: job.client.AccountID,
+				Region:    job.client.Region,
+				Name:      names[job.client.AccountID],
+			}, outputType)
+		}
+
+		key := job.key
+		lastKey = &key
+
+		for _, printed := range job.result.printed {
+			Print(printed.unprotected, outputType)
+			PrintProtected(printed.protected, outputType)
+		}
+
+		result.Resources = append(result.Resources, job.result.Resources...)
+		result.Protected = append(result.Protected, job.result.Protected...)
+		result.StackOwned = append(result.StackOwned, job.result.StackOwned...)
+
+		for child, parent := range job.result.Parents {
+			if result.Parents == nil {
+				result.Parents = Parents{}
 			}
+			result.Parents[child] = parent
 		}
 	}
 
 	return result
 }
 
-func getInlineUserPolicies(ctx context.Context, users []terraform.Resource, client aws.Client,
-	provider *provider.TerraformProvider) []terraform.Resource {
-	var result []terraform.Resource
+// listJob lists the resources of a type via a client.
+type listJob struct {
+	key    aws.ClientKey
+	client aws.Client
+	// rType is empty if the job only reports errors of the client (e.g., the account ID couldn't be retrieved).
+	rType string
+	// done is closed once the result is set.
+	done   chan struct{}
+	result TypeListResult
+}
 
-	for _, user := range users {
-		pg := iam.NewListUserPoliciesPaginator(client.Iamconn, &iam.ListUserPoliciesInput{
-			UserName: &user.ID,
-		})
+// TypeListResult is the result of listing the resources of a type via a client.
+type TypeListResult struct {
+	// Resources are the resources that match the filter and aren't protected.
+	Resources []terraform.Resource
+	// Protected are the resources that match the filter, but are protected.
+	Protected []terraform.Resource
+	// StackOwned are the resources that are skipped, since they are owned by a CloudFormation stack.
+	StackOwned []terraform.Resource
+	// Parents are the resources that the resources of dependent types belong to.
+	Parents Parents
+	Errors  []ListError
+	// printed are the resources to print in order, i.e., the ones of the listed type followed
+	// by the ones of dependent types (e.g., policy attachments of IAM users).
+	printed []printedResources
+}
+
+type printedResources struct {
+	unprotected []terraform.Resource
+	protected   []terraform.Resource
+}
+
+// setAccountIDs sets the account ID of copies of all clients whose account ID isn't set yet concurrently.
+// It returns the copies and the errors per client whose account ID couldn't be retrieved.
+func setAccountIDs(ctx context.Context, clients map[aws.ClientKey]aws.Client,
+	sem chan struct{}) (map[aws.ClientKey]aws.Client, map[aws.ClientKey]error) {
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+
+	result := map[aws.ClientKey]aws.Client{}
+	errs := map[aws.ClientKey]error{}
+
+	for key, client := range clients {
+		if client.AccountID != "" {
+			result[key] = client
+			continue
+		}
+
+		wg.Add(1)
+
+		go func(key aws.ClientKey, client aws.Client) {
+			sem <- struct{}{}
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			err := client.SetAccountID(ctx)
+
+			lock.Lock()
+			defer lock.Unlock()
 
-		for pg.HasMorePages() {
-			page, err := pg.NextPage(ctx)
 			if err != nil {
-				fmt.Fprint(os.Stderr, color.RedString("Error: %s\n", err))
-				continue
+				errs[key] = err
+				return
 			}
 
-			for _, inlinePolicy := range page.PolicyNames {
-				r := terraform.Resource{
-					Type: "aws_iam_user_policy",
-					ID:   user.ID + ":" + inlinePolicy,
-				}
+			result[key] = client
+		}(key, client)
+	}
 
-				r.UpdatableResource = terradozerRes.New(r.Type, r.ID, nil, provider)
+	wg.Wait()
 
-				err := r.UpdateState()
-				if err != nil {
-					fmt.Fprint(os.Stderr, color.RedString("Error: %s\n", err))
-					continue
-				}
+	return result, errs
+}
 
-				result = append(result, r)
-			}
+// newListJobs returns a job for each combination of client and type in the order the results are printed.
+// For a client whose account ID couldn't be retrieved, a single job reporting the error is returned instead.
+func newListJobs(clients map[aws.ClientKey]aws.Client, keys []aws.ClientKey, accountErrs map[aws.ClientKey]error,
+	types []string) []*listJob {
+	var jobs []*listJob
+
+	for _, key := range keys {
+		if err, ok := accountErrs[key]; ok {
+			client := aws.Client{Profile: key.Profile, Region: key.Region}
+
+			job := &listJob{key: key, client: client, done: make(chan struct{})}
+			job.result.Errors = []ListError{newListError(client, "", fmt.Errorf("failed to get account ID: %s", err))}
+
+			jobs = append(jobs, job)
+
+			continue
+		}
+
+		client := clients[key]
+
+		for _, rType := range types {
+			jobs = append(jobs, &listJob{key: key, client: client, rType: rType, done: make(chan struct{})})
 		}
 	}
 
-	return result
+	return jobs
 }
 
-func getPolicyAttachments(policies []terraform.Resource, provider *provider.TerraformProvider) []terraform.Resource {
-	var result []terraform.Resource
+// listType lists the resources of a type via a client that match the filter of the config,
+// including resources of dependent types that are not listed on their own (see Expander).
+func listType(ctx context.Context, cfg *Config, client aws.Client, rType string,
+	providers map[aws.ClientKey]provider.TerraformProvider, stacks *stackOwnersCache) TypeListResult {
+	var result TypeListResult
 
-	for _, policy := range policies {
-		arn, err := awslsRes.GetAttribute("arn", &policy)
-		if err != nil {
-			fmt.Fprint(os.Stderr, color.RedString("Error: %s\n", err))
-			continue
+	addErrors := func(rType string, errs ...error) {
+		for _, err := range errs {
+			result.Errors = append(result.Errors, newListError(client, rType, err))
 		}
+	}
+
+	// protect excludes the given resources that are managed by Terraform, adds the remaining ones to the result
+	// and returns the ones that are not protected
+	protect := func(res []terraform.Resource) []terraform.Resource {
+		unprotected, protectedRes := cfg.Protect.Apply(cfg.Managed.Exclude(res))
+
+		result.printed = append(result.printed, printedResources{unprotected: unprotected, protected: protectedRes})
 
-		r := terraform.Resource{
-			Type: "aws_iam_policy_attachment",
-			// Note: ID is only set for pretty printing (could be also left empty)
-			ID: policy.ID,
+		for _, r := range protectedRes {
+			r.Region = client.Region
+			r.Profile = client.Profile
+			r.AccountID = client.AccountID
+
+			result.Protected = append(result.Protected, r)
 		}
 
-		r.UpdatableResource = terradozerRes.New(r.Type, r.ID, map[string]cty.Value{
-			"policy_arn": cty.StringVal(arn),
-		}, provider)
+		return unprotected
+	}
+
+	resources, err := awsls.ListResourcesByType(ctx, &client, rType)
+	if err != nil {
+		addErrors(rType, err)
+		return result
+	}
+
+	resourcesWithStates, errs := terraform.UpdateStates(resources, providers, updateStatesParallel, true)
+	addErrors(rType, errs...)
 
-		err = r.UpdateState()
+	// resources owned by a stack must not be deleted individually, so none are returned
+	// if it's unknown which ones are owned
+	if cfg.Filter.skipsStackOwned(rType) {
+		owners, err := stacks.get(ctx, client)
 		if err != nil {
-			fmt.Fprint(os.Stderr, color.RedString("Error: %s\n", err))
+			addErrors(rType, fmt.Errorf("failed to find resources owned by CloudFormation stacks: %s", err))
+			return result
+		}
+
+		SetStackTags(resourcesWithStates, owners)
+	}
+
+	filteredRes, errs := cfg.Filter.apply(resourcesWithStates, cfg.AccountNames)
+	if cfg.Strict {
+		addErrors(rType, errs...)
+	}
+	stackOwned := cfg.Filter.stackOwned(resourcesWithStates, cfg.AccountNames)
+	if cfg.MarkedBefore != nil {
+		filteredRes = SelectMarked(filteredRes, *cfg.MarkedBefore)
+		stackOwned = SelectMarked(stackOwned, *cfg.MarkedBefore)
+	}
+
+	for _, r := range stackOwned {
+		r.Region = client.Region
+		r.Profile = client.Profile
+		r.AccountID = client.AccountID
+
+		result.StackOwned = append(result.StackOwned, r)
+	}
+
+	filteredRes = protect(filteredRes)
+
+	p := providers[aws.ClientKey{Profile: client.Profile, Region: client.Region}]
+
+	parents := filteredRes
+	for _, e := range Expanders(rType) {
+		if e.Enabled != nil && !e.Enabled(cfg.Options) {
 			continue
 		}
 
-		result = append(result, r)
+		var childRes []terraform.Resource
+
+		for _, parent := range parents {
+			children, errs := e.Expand(ctx, NewExpanderClients(client), parent)
+			addErrors(e.ChildType, errs...)
+
+			res, errs := updateChildStates(children, &p)
+			addErrors(e.ChildType, errs...)
+
+			for _, r := range res {
+				if result.Parents == nil {
+					result.Parents = Parents{}
+				}
+				result.Parents[r.UpdatableResource] = parent
+			}
+
+			childRes = append(childRes, res...)
+		}
+
+		filteredRes = append(filteredRes, protect(childRes)...)
+	}
+
+	for _, r := range filteredRes {
+		r.Region = client.Region
+		r.Profile = client.Profile
+		r.AccountID = client.AccountID
+
+		result.Resources = append(result.Resources, r)
 	}
 
 	return result
 }
 
-func getEfsMountTargets(ctx context.Context, efsFileSystems []terraform.Resource, client aws.Client,
-	provider *provider.TerraformProvider) []terraform.Resource {
-	var result []terraform.Resource
+// stackOwnersCache looks up the owning stacks by physical resource ID (see StackOwners) only once
+// per account and region.
+type stackOwnersCache struct {
+	mu      sync.Mutex
+	entries map[aws.ClientKey]*stackOwnersEntry
+}
 
-	for _, fs := range efsFileSystems {
-		// TODO result is paginated, but there is no paginator API function
-		req, err := client.Efsconn.DescribeMountTargets(ctx, &efs.DescribeMountTargetsInput{
-			FileSystemId: &fs.ID,
-		})
+type stackOwnersEntry struct {
+	once   sync.Once
+	owners map[string]Stack
+	err    error
+}
 
-		if err != nil {
-			fmt.Fprint(os.Stderr, color.RedString("Error: %s\n", err))
-			continue
+// get returns the owning stacks of the account and region of a client. The error of a failed lookup is returned
+// to every caller.
+func (c *stackOwnersCache) get(ctx context.Context, client aws.Client) (map[string]Stack, error) {
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = map[aws.ClientKey]*stackOwnersEntry{}
+	}
+
+	key := aws.ClientKey{Profile: client.Profile, Region: client.Region}
+
+	e, ok := c.entries[key]
+	if !ok {
+		e = &stackOwnersEntry{}
+		c.entries[key] = e
+	}
+	c.mu.Unlock()
+
+	e.once.Do(func() {
+		e.owners, e.err = StackOwners(ctx, client.Cloudformationconn)
+	})
+
+	return e.owners, e.err
+}
+
+func newListError(client aws.Client, rType string, err error) ListError {
+	return ListError{
+		Profile:   client.Profile,
+		AccountID: client.AccountID,
+		Region:    client.Region,
+		Type:      rType,
+		Err:       err,
+	}
+}
+
+// sortedClientKeys returns the keys of the clients sorted by profile and region.
+func sortedClientKeys(clients map[aws.ClientKey]aws.Client) []aws.ClientKey {
+	keys := make([]aws.ClientKey, 0, len(clients))
+	for k := range clients {
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Profile == keys[j].Profile {
+			return keys[i].Region < keys[j].Region
 		}
 
-		for _, mountTarget := range req.MountTargets {
-			r := terraform.Resource{
-				Type: "aws_efs_mount_target",
-				ID:   *mountTarget.MountTargetId,
-			}
+		return keys[i].Profile < keys[j].Profile
+	})
 
-			r.UpdatableResource = terradozerRes.New(r.Type, r.ID, nil, provider)
+	return keys
+}
 
-			err = r.UpdateState()
-			if err != nil {
-				fmt.Fprint(os.Stderr, color.RedString("Error: %s\n", err))
-				continue
+// AccountRegion is the combination of AWS account and region that a resource belongs to.
+type AccountRegion struct {
+	Profile   string
+	AccountID string
+	Region    string
+	// Name is the name of the account if it's a member account of an organization (see AccountNames).
+	Name string
+}
+
+func (a AccountRegion) String() string {
+	name := a.Profile
+	if name == a.AccountID {
+		// member accounts of an organization are keyed by their ID
+		name = a.Name
+	}
+
+	account := a.AccountID
+	if name != "" {
+		account = fmt.Sprintf("%s (%s)", name, a.AccountID)
+	}
+
+	return fmt.Sprintf("%s, %s", account, a.Region)
+}
+
+// GroupByAccountRegion groups the given resources by account and region.
+// The groups are returned in the order they first appear in.
+func GroupByAccountRegion(res []terraform.Resource) ([]AccountRegion, map[AccountRegion][]terraform.Resource) {
+	var groups []AccountRegion
+
+	result := map[AccountRegion][]terraform.Resource{}
+
+	for _, r := range res {
+		g := AccountRegion{Profile: r.Profile, AccountID: r.AccountID, Region: r.Region}
+
+		if _, ok := result[g]; !ok {
+			groups = append(groups, g)
+		}
+
+		result[g] = append(result[g], r)
+	}
+
+	return groups, result
+}
+
+// PrintGrouped prints the given resources grouped by account and region and,
+// within each group, resources of the same type together.
+func PrintGrouped(res []terraform.Resource, outputType string) {
+	groups, resByGroup := GroupByAccountRegion(res)
+
+	for _, g := range groups {
+		if len(groups) > 1 {
+			printAccountRegion(g, outputType)
+		}
+
+		groupRes := resByGroup[g]
+
+		for i := 0; i < len(groupRes); {
+			j := i
+			for j < len(groupRes) && groupRes[j].Type == groupRes[i].Type {
+				j++
 			}
 
-			result = append(result, r)
+			Print(groupRes[i:j], outputType)
+
+			i = j
+		}
+	}
+}
+
+// printAccountRegion prints a header for the resources of an account and region (only for string output).
+func printAccountRegion(a AccountRegion, outputType string) {
+	if strings.ToLower(outputType) != "string" {
+		return
+	}
+
+	fmt.Printf("\n%s\n", color.New(color.Bold).Sprintf("Resources in %s", a))
+}
+
+// Destroyable returns the given resources as resources that can be destroyed via their Terraform state.
+func Destroyable(resources []terraform.Resource) []terradozerRes.DestroyableResource {
+	var result []terradozerRes.DestroyableResource
+
+	for _, r := range resources {
+		d, ok := AsDestroyable(r)
+		if !ok {
+			log.WithFields(log.Fields{
+				"type": r.Type,
+				"id":   r.ID,
+			}).Debug("resource cannot be destroyed")
+
+			continue
 		}
+
+		result = append(result, d)
 	}
 
 	return result
 }
 
-func print(res []terraform.Resource, outputType string) {
+// AsDestroyable returns a resource as a resource that can be destroyed, which knows the account and region
+// it belongs to (see Graph). The same resource always results in an equal value.
+func AsDestroyable(r terraform.Resource) (terradozerRes.DestroyableResource, bool) {
+	d, ok := r.UpdatableResource.(terradozerRes.DestroyableResource)
+	if !ok {
+		return nil, false
+	}
+
+	return scopedResource{
+		DestroyableResource: d,
+		scope:               AccountRegion{Profile: r.Profile, AccountID: r.AccountID, Region: r.Region},
+	}, true
+}
+
+// scopedResource is a resource that can be destroyed together with the account and region it belongs to.
+type scopedResource struct {
+	terradozerRes.DestroyableResource
+	scope AccountRegion
+}
+
+// State returns the Terraform state of the resource or nil, if the resource doesn't store a state.
+func (r scopedResource) State() *cty.Value {
+	return stateOf(r.DestroyableResource)
+}
+
+// DestroyContext destroys the resource, which is canceled with the given context if the resource supports it.
+func (r scopedResource) DestroyContext(ctx context.Context) error {
+	return destroy(ctx, r.DestroyableResource)
+}
+
+// IsSupportedOutputType checks whether resources can be printed in the given output format.
+func IsSupportedOutputType(outputType string) bool {
+	switch strings.ToLower(outputType) {
+	case "string", "json", "yaml":
+		return true
+	default:
+		return false
+	}
+}
+
+// Print prints the given resources in the given output format (string, JSON or YAML).
+func Print(res []terraform.Resource, outputType string) {
 	if len(res) == 0 {
 		return
 	}
@@ -242,12 +645,37 @@ func print(res []terraform.Resource, outputType string) {
 	case "yaml":
 		printYaml(res)
 	default:
-		log.WithField("output", outputType).Fatal("Unsupported output type")
+		log.WithField("output", outputType).Error("Unsupported output type")
+	}
+}
+
+// PrintProtected prints the given resources as skipped, since they are protected from deletion.
+// Other than for string output, protected resources are logged.
+func PrintProtected(res []terraform.Resource, outputType string) {
+	if len(res) == 0 {
+		return
+	}
+
+	if strings.ToLower(outputType) != "string" {
+		for _, r := range res {
+			log.WithFields(log.Fields{
+				"type": r.Type,
+				"id":   r.ID,
+			}).Info("skipped (protected)")
+		}
+
+		return
 	}
+
+	printStringWithStatus(res, "Skipped (protected)")
 }
 
 func printString(res []terraform.Resource) {
-	fmt.Printf("\n\t---\n\tType: %s\n\tFound: %d\n\n", res[0].Type, len(res))
+	printStringWithStatus(res, "Found")
+}
+
+func printStringWithStatus(res []terraform.Resource, status string) {
+	fmt.Printf("\n\t---\n\tType: %s\n\t%s: %d\n\n", res[0].Type, status, len(res))
 
 	for _, r := range res {
 		printStat := fmt.Sprintf("\t\tId:\t\t%s", r.ID)
@@ -277,7 +705,8 @@ func printString(res []terraform.Resource) {
 func printJson(res []terraform.Resource) {
 	b, err := json.Marshal(res)
 	if err != nil {
-		log.WithError(err).Fatal("failed to marshal resources into JSON")
+		log.WithError(err).Error("failed to marshal resources into JSON")
+		return
 	}
 
 	fmt.Print(string(b))
@@ -286,7 +715,8 @@ func printJson(res []terraform.Resource) {
 func printYaml(res []terraform.Resource) {
 	b, err := yaml.Marshal(res)
 	if err != nil {
-		log.WithError(err).Fatal("failed to marshal resources into YAML")
+		log.WithError(err).Error("failed to marshal resources into YAML")
+		return
 	}
 
 	fmt.Print(string(b))
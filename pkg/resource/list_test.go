@@ -0,0 +1,225 @@
+package resource_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jckuester/awstools-lib/aws"
+	"github.com/jckuester/awstools-lib/terraform"
+	"github.com/jckuester/awsweeper/pkg/resource"
+	terradozerRes "github.com/jckuester/terradozer/pkg/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupByAccountRegion(t *testing.T) {
+	res := []terraform.Resource{
+		{Type: "aws_vpc", ID: "vpc-1", Profile: "dev", AccountID: "111111111111", Region: "us-west-2"},
+		{Type: "aws_vpc", ID: "vpc-2", Profile: "dev", AccountID: "111111111111", Region: "eu-west-1"},
+		{Type: "aws_subnet", ID: "subnet-1", Profile: "dev", AccountID: "111111111111", Region: "us-west-2"},
+	}
+
+	groups, resByGroup := resource.GroupByAccountRegion(res)
+
+	usWest2 := resource.AccountRegion{Profile: "dev", AccountID: "111111111111", Region: "us-west-2"}
+	euWest1 := resource.AccountRegion{Profile: "dev", AccountID: "111111111111", Region: "eu-west-1"}
+
+	assert.Equal(t, []resource.AccountRegion{usWest2, euWest1}, groups)
+	assert.Equal(t, []terraform.Resource{res[0], res[2]}, resByGroup[usWest2])
+	assert.Equal(t, []terraform.Resource{res[1]}, resByGroup[euWest1])
+	assert.Equal(t, "dev (111111111111), us-west-2", usWest2.String())
+
+	// member accounts of an organization are keyed by their ID
+	member := resource.AccountRegion{Profile: "222222222222", AccountID: "222222222222", Region: "us-west-2",
+		Name: "sandbox"}
+	assert.Equal(t, "sandbox (222222222222), us-west-2", member.String())
+}
+
+func TestParents_Of(t *testing.T) {
+	user := terraform.Resource{Type: "aws_iam_user", ID: "alice",
+		UpdatableResource: terradozerRes.New("aws_iam_user", "alice", nil, nil)}
+	attachment := terraform.Resource{Type: "aws_iam_user_policy_attachment", ID: "arn:aws:iam::aws:policy/foo",
+		AccountID: "111111111111", Region: "us-west-2",
+		UpdatableResource: terradozerRes.New("aws_iam_user_policy_attachment", "arn:aws:iam::aws:policy/foo", nil, nil)}
+	// same type and ID, but belongs to another user
+	otherAttachment := terraform.Resource{Type: "aws_iam_user_policy_attachment", ID: "arn:aws:iam::aws:policy/foo",
+		UpdatableResource: terradozerRes.New("aws_iam_user_policy_attachment", "arn:aws:iam::aws:policy/foo", nil, nil)}
+
+	parents := resource.Parents{attachment.UpdatableResource: user}
+
+	parent, ok := parents.Of(attachment)
+	assert.True(t, ok)
+	assert.Equal(t, "alice", parent.ID)
+	assert.Equal(t, "111111111111", parent.AccountID)
+
+	_, ok = parents.Of(otherAttachment)
+	assert.False(t, ok)
+
+	_, ok = parents.Of(user)
+	assert.False(t, ok)
+}
+
+// fakeLister lists one resource per client and type and records how many lists run concurrently,
+// overall and per service within an account and region.
+type fakeLister struct {
+	mu         sync.Mutex
+	running    int
+	maxRunning int
+	byService  map[string]int
+	maxService int
+	// delay returns how long listing a type takes.
+	delay func(rType string) time.Duration
+	// errs are the errors when listing a type.
+	errs map[string]error
+}
+
+func (f *fakeLister) list(_ context.Context, client aws.Client, rType string) resource.TypeListResult {
+	service := fmt.Sprintf("%s/%s/%s", client.Profile, client.Region, serviceOf(rType))
+
+	f.mu.Lock()
+	f.running++
+	f.byService[service]++
+	if f.running > f.maxRunning {
+		f.maxRunning = f.running
+	}
+	if f.byService[service] > f.maxService {
+		f.maxService = f.byService[service]
+	}
+	f.mu.Unlock()
+
+	time.Sleep(f.delay(rType))
+
+	f.mu.Lock()
+	f.running--
+	f.byService[service]--
+	f.mu.Unlock()
+
+	if err, ok := f.errs[rType]; ok {
+		return resource.TypeListResult{Errors: []resource.ListError{{Profile: client.Profile, Region: client.Region,
+			Type: rType, Err: err}}}
+	}
+
+	return resource.TypeListResult{Resources: []terraform.Resource{{
+		Type:      rType,
+		ID:        client.Profile + "/" + client.Region,
+		Profile:   client.Profile,
+		AccountID: client.AccountID,
+		Region:    client.Region,
+	}}}
+}
+
+func serviceOf(rType string) string {
+	switch rType {
+	case "aws_iam_role", "aws_iam_user":
+		return "iam"
+	default:
+		return "ec2"
+	}
+}
+
+func newClients(profiles []string, regions []string) map[aws.ClientKey]aws.Client {
+	clients := map[aws.ClientKey]aws.Client{}
+
+	for _, profile := range profiles {
+		for _, region := range regions {
+			clients[aws.ClientKey{Profile: profile, Region: region}] = aws.Client{
+				Profile:   profile,
+				AccountID: "111111111111",
+				Region:    region,
+			}
+		}
+	}
+
+	return clients
+}
+
+func TestListTypes_Order(t *testing.T) {
+	types := []string{"aws_vpc", "aws_iam_role", "aws_subnet", "aws_iam_user"}
+
+	// lists of later types finish first
+	lister := &fakeLister{
+		byService: map[string]int{},
+		delay: func(rType string) time.Duration {
+			for i, t := range types {
+				if t == rType {
+					return time.Duration(len(types)-i) * 5 * time.Millisecond
+				}
+			}
+			return 0
+		},
+		errs: map[string]error{"aws_subnet": errors.New("AccessDenied")},
+	}
+
+	clients := newClients([]string{"prod", "dev"}, []string{"us-west-2", "eu-west-1"})
+
+	result := resource.ListTypes(context.Background(), clients, types, lister.list, nil, "json", 10)
+
+	var got []string
+	for _, r := range result.Resources {
+		got = append(got, r.ID+" "+r.Type)
+	}
+
+	var want []string
+	for _, key := range []string{"dev/eu-west-1", "dev/us-west-2", "prod/eu-west-1", "prod/us-west-2"} {
+		for _, rType := range []string{"aws_vpc", "aws_iam_role", "aws_iam_user"} {
+			want = append(want, key+" "+rType)
+		}
+	}
+
+	assert.Equal(t, want, got)
+
+	require.Len(t, result.Errors, 4)
+	assert.Equal(t, "dev", result.Errors[0].Profile)
+	assert.Equal(t, "eu-west-1", result.Errors[0].Region)
+	assert.Equal(t, "prod", result.Errors[3].Profile)
+	assert.Equal(t, "us-west-2", result.Errors[3].Region)
+}
+
+func TestListTypes_Concurrency(t *testing.T) {
+	types := []string{"aws_instance", "aws_vpc", "aws_subnet", "aws_security_group", "aws_iam_role", "aws_iam_user"}
+
+	tests := []struct {
+		name           string
+		parallel       int
+		wantMaxRunning int
+	}{
+		{
+			name:           "limited by parallel",
+			parallel:       3,
+			wantMaxRunning: 3,
+		},
+		{
+			name:     "limited per service",
+			parallel: 20,
+			// 2 lists of ec2 and iam per account and region
+			wantMaxRunning: 8,
+		},
+		{
+			name:           "sequential",
+			parallel:       1,
+			wantMaxRunning: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lister := &fakeLister{
+				byService: map[string]int{},
+				delay:     func(string) time.Duration { return 20 * time.Millisecond },
+			}
+
+			clients := newClients([]string{"dev"}, []string{"us-west-2", "eu-west-1"})
+
+			result := resource.ListTypes(context.Background(), clients, types, lister.list, nil, "json", tt.parallel)
+
+			assert.Len(t, result.Resources, len(types)*len(clients))
+			assert.Empty(t, result.Errors)
+
+			assert.Equal(t, tt.wantMaxRunning, lister.maxRunning)
+			assert.LessOrEqual(t, lister.maxService, 2)
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+	awslsRes "github.com/jckuester/awsls/resource"
+	"github.com/jckuester/awstools-lib/aws"
+	"github.com/jckuester/awstools-lib/terraform"
+)
+
+// MarkTagKey is the key of the tag that marks a resource for deletion. The value of the tag is the time
+// the resource has been marked at (in RFC3339 format).
+const MarkTagKey = "awsweeper:marked-for-deletion"
+
+// MarkError is an error that occurred while marking a resource for deletion.
+type MarkError struct {
+	Resource terraform.Resource
+	Err      error
+}
+
+func (e MarkError) Error() string {
+	return e.Err.Error()
+}
+
+// MarkResult is the result of marking resources for deletion.
+type MarkResult struct {
+	// Marked are the resources that have been tagged.
+	Marked []terraform.Resource
+	// AlreadyMarked are the resources that already had been marked before (their tag is kept).
+	AlreadyMarked []terraform.Resource
+	// Unsupported are the resources that cannot be marked, since their type doesn't support tags or they have no ARN.
+	Unsupported []terraform.Resource
+	Failed      []MarkError
+}
+
+// MarkedAt returns the time a resource has been marked for deletion at.
+func MarkedAt(r terraform.Resource) (time.Time, bool) {
+	value, ok := r.Tags[MarkTagKey]
+	if !ok {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"type": r.Type,
+			"id":   r.ID,
+		}).WithError(err).Debug("failed to parse time of mark tag")
+
+		return time.Time{}, false
+	}
+
+	return t, true
+}
+
+// SelectMarked returns the resources that have been marked for deletion before the given time.
+func SelectMarked(res []terraform.Resource, before time.Time) []terraform.Resource {
+	var result []terraform.Resource
+
+	for _, r := range res {
+		markedAt, ok := MarkedAt(r)
+		if ok && markedAt.Before(before) {
+			result = append(result, r)
+		}
+	}
+
+	return result
+}
+
+// TaggingAPI is the part of the Resource Groups Tagging API used to mark resources for deletion.
+type TaggingAPI interface {
+	TagResources(ctx context.Context, params *resourcegroupstaggingapi.TagResourcesInput,
+		optFns ...func(*resourcegroupstaggingapi.Options)) (*resourcegroupstaggingapi.TagResourcesOutput, error)
+}
+
+// NewTaggingClients creates a Resource Groups Tagging API client for each of the given combinations of profile
+// (or the default credential provider chain if empty) and region.
+func NewTaggingClients(ctx context.Context, keys []aws.ClientKey) (map[aws.ClientKey]TaggingAPI, error) {
+	result := map[aws.ClientKey]TaggingAPI{}
+
+	for _, key := range keys {
+		opts := []func(*config.LoadOptions) error{config.WithRegion(key.Region)}
+		if key.Profile != "" {
+			opts = append(opts, config.WithSharedConfigProfile(key.Profile))
+		}
+
+		cfg, err := config.LoadDefaultConfig(ctx, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create tagging client (profile=%s, region=%s): %s",
+				key.Profile, key.Region, err)
+		}
+
+		result[key] = resourcegroupstaggingapi.NewFromConfig(cfg)
+	}
+
+	return result, nil
+}
+
+// maxTagResources is the maximum number of resources that can be tagged with one request.
+const maxTagResources = 20
+
+// markBatch are resources of the same account and region that are tagged with one request.
+type markBatch struct {
+	client    TaggingAPI
+	resources []terraform.Resource
+	arns      []string
+	result    MarkResult
+}
+
+// Mark tags the given resources with MarkTagKey and the given time as value via the Resource Groups Tagging API
+// client matching the profile and region of a resource. Resources that are already marked keep their tag.
+//
+// Resources are tagged by their ARN (i.e., the arn attribute of their Terraform state), so that nothing
+// but the tag is changed. Resources without ARN are returned as unsupported.
+func Mark(ctx context.Context, res []terraform.Resource, clients map[aws.ClientKey]TaggingAPI, now time.Time,
+	parallel int) MarkResult {
+	var result MarkResult
+	var batches []*markBatch
+
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	batchByKey := map[aws.ClientKey]*markBatch{}
+
+	for _, r := range res {
+		if _, ok := r.Tags[MarkTagKey]; ok {
+			result.AlreadyMarked = append(result.AlreadyMarked, r)
+			continue
+		}
+
+		arn, err := GetAttribute(&r, "arn")
+		if !awslsRes.SupportsTags(r.Type) || err != nil || arn == "" {
+			result.Unsupported = append(result.Unsupported, r)
+			continue
+		}
+
+		key := aws.ClientKey{Profile: r.Profile, Region: r.Region}
+
+		client, ok := clients[key]
+		if !ok {
+			result.Failed = append(result.Failed, MarkError{
+				Resource: r,
+				Err:      fmt.Errorf("no tagging client found for profile=%s, region=%s", r.Profile, r.Region),
+			})
+			continue
+		}
+
+		b, ok := batchByKey[key]
+		if !ok || len(b.resources) == maxTagResources {
+			b = &markBatch{client: client}
+			batchByKey[key] = b
+			batches = append(batches, b)
+		}
+
+		b.resources = append(b.resources, r)
+		b.arns = append(b.arns, arn)
+	}
+
+	var wg sync.WaitGroup
+
+	sem := make(chan struct{}, parallel)
+
+	for _, b := range batches {
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(b *markBatch) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			b.tag(ctx, now.UTC().Format(time.RFC3339))
+		}(b)
+	}
+
+	wg.Wait()
+
+	for _, b := range batches {
+		result.Marked = append(result.Marked, b.result.Marked...)
+		result.Failed = append(result.Failed, b.result.Failed...)
+	}
+
+	return result
+}
+
+// tag adds the mark tag with the given value to the resources of the batch.
+func (b *markBatch) tag(ctx context.Context, value string) {
+	output, err := b.client.TagResources(ctx, &resourcegroupstaggingapi.TagResourcesInput{
+		ResourceARNList: b.arns,
+		Tags:            map[string]string{MarkTagKey: value},
+	})
+
+	for i, r := range b.resources {
+		if err != nil {
+			b.result.Failed = append(b.result.Failed, MarkError{Resource: r, Err: err})
+			continue
+		}
+
+		if failure, ok := output.FailedResourcesMap[b.arns[i]]; ok {
+			message := ""
+			if failure.ErrorMessage != nil {
+				message = *failure.ErrorMessage
+			}
+
+			b.result.Failed = append(b.result.Failed, MarkError{
+				Resource: r,
+				Err:      fmt.Errorf("%s: %s", failure.ErrorCode, message),
+			})
+			continue
+		}
+
+		b.result.Marked = append(b.result.Marked, r)
+	}
+}
@@ -0,0 +1,134 @@
+package resource_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi/types"
+	"github.com/jckuester/awstools-lib/aws"
+	"github.com/jckuester/awstools-lib/terraform"
+	"github.com/jckuester/awsweeper/pkg/resource"
+	terradozerRes "github.com/jckuester/terradozer/pkg/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestSelectMarked(t *testing.T) {
+	res := []terraform.Resource{
+		{Type: "aws_vpc", ID: "marked-long-ago", Tags: map[string]string{resource.MarkTagKey: "2018-11-10T05:00:00Z"}},
+		{Type: "aws_vpc", ID: "marked-recently", Tags: map[string]string{resource.MarkTagKey: "2018-11-16T05:00:00Z"}},
+		{Type: "aws_vpc", ID: "invalid-mark", Tags: map[string]string{resource.MarkTagKey: "yesterday"}},
+		{Type: "aws_vpc", ID: "not-marked", Tags: map[string]string{"foo": "bar"}},
+	}
+
+	result := resource.SelectMarked(res, time.Date(2018, 11, 15, 0, 0, 0, 0, time.UTC))
+
+	require.Len(t, result, 1)
+	assert.Equal(t, "marked-long-ago", result[0].ID)
+}
+
+type fakeTagging struct {
+	mu       sync.Mutex
+	requests [][]string
+	failed   map[string]string
+	err      error
+}
+
+func (f *fakeTagging) TagResources(_ context.Context, params *resourcegroupstaggingapi.TagResourcesInput,
+	_ ...func(*resourcegroupstaggingapi.Options)) (*resourcegroupstaggingapi.TagResourcesOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, params.ResourceARNList)
+
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	output := &resourcegroupstaggingapi.TagResourcesOutput{
+		FailedResourcesMap: map[string]types.FailureInfo{},
+	}
+	for _, arn := range params.ResourceARNList {
+		if code, ok := f.failed[arn]; ok {
+			output.FailedResourcesMap[arn] = types.FailureInfo{
+				ErrorCode:    types.ErrorCode(code),
+				ErrorMessage: awssdk.String("denied"),
+			}
+		}
+	}
+
+	return output, nil
+}
+
+func TestMark(t *testing.T) {
+	newVpc := func(id, region string, tags map[string]string) terraform.Resource {
+		state := cty.ObjectVal(map[string]cty.Value{
+			"id":  cty.StringVal(id),
+			"arn": cty.StringVal("arn:aws:ec2:" + region + ":123456789012:vpc/" + id),
+		})
+
+		return terraform.Resource{
+			Type:              "aws_vpc",
+			ID:                id,
+			Region:            region,
+			Tags:              tags,
+			UpdatableResource: terradozerRes.NewWithState("aws_vpc", id, nil, &state),
+		}
+	}
+
+	now := time.Date(2018, 11, 17, 5, 0, 0, 0, time.UTC)
+
+	t.Run("batches per region", func(t *testing.T) {
+		west := &fakeTagging{}
+		east := &fakeTagging{failed: map[string]string{"arn:aws:ec2:us-east-1:123456789012:vpc/vpc-e": "AccessDenied"}}
+
+		var res []terraform.Resource
+		for i := 0; i < 21; i++ {
+			res = append(res, newVpc(fmt.Sprintf("vpc-%d", i), "us-west-2", nil))
+		}
+		res = append(res,
+			newVpc("vpc-e", "us-east-1", nil),
+			newVpc("vpc-marked", "us-west-2", map[string]string{resource.MarkTagKey: "2018-11-10T05:00:00Z"}),
+			terraform.Resource{Type: "aws_vpc", ID: "vpc-no-state", Region: "us-west-2"},
+			newVpc("vpc-no-client", "eu-west-1", nil),
+		)
+
+		result := resource.Mark(context.Background(), res, map[aws.ClientKey]resource.TaggingAPI{
+			{Region: "us-west-2"}: west,
+			{Region: "us-east-1"}: east,
+		}, now, 2)
+
+		require.Len(t, west.requests, 2)
+		assert.ElementsMatch(t, []int{20, 1}, []int{len(west.requests[0]), len(west.requests[1])})
+		assert.Len(t, east.requests, 1)
+
+		assert.Len(t, result.Marked, 21)
+		require.Len(t, result.AlreadyMarked, 1)
+		assert.Equal(t, "vpc-marked", result.AlreadyMarked[0].ID)
+		require.Len(t, result.Unsupported, 1)
+		assert.Equal(t, "vpc-no-state", result.Unsupported[0].ID)
+
+		require.Len(t, result.Failed, 2)
+		assert.Equal(t, "vpc-no-client", result.Failed[0].Resource.ID)
+		assert.Equal(t, "vpc-e", result.Failed[1].Resource.ID)
+		assert.EqualError(t, result.Failed[1], "AccessDenied: denied")
+	})
+
+	t.Run("request fails", func(t *testing.T) {
+		client := &fakeTagging{err: errors.New("throttled")}
+
+		result := resource.Mark(context.Background(), []terraform.Resource{newVpc("vpc-1", "us-west-2", nil)},
+			map[aws.ClientKey]resource.TaggingAPI{{Region: "us-west-2"}: client}, now, 0)
+
+		assert.Empty(t, result.Marked)
+		require.Len(t, result.Failed, 1)
+		assert.EqualError(t, result.Failed[0], "throttled")
+	})
+}
@@ -0,0 +1,444 @@
+package resource
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/aws-sdk-go-v2/service/resourcegroupstaggingapi"
+	"github.com/jckuester/awstools-lib/aws"
+	"github.com/jckuester/terradozer/pkg/provider"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// MemberAccount is an active account of an AWS Organization.
+type MemberAccount struct {
+	ID   string
+	Name string
+}
+
+// Organization creates AWS clients and Terraform AWS Providers for the member accounts of an AWS Organization
+// by assuming a role in each member account with the credentials of a central account.
+type Organization struct {
+	// client is the client of the central account.
+	client   aws.Client
+	profile  string
+	roleName string
+	// region is the region of the central account, which is used for member accounts if no regions are given.
+	region string
+
+	credentialsLock sync.Mutex
+	credentials     map[string]*awssdk.CredentialsCache
+}
+
+// NewOrganization creates an organization whose member accounts are accessed by assuming the role
+// with the given name via the credentials of the given profile (or the default credential provider chain if empty).
+// If the profile has no region configured, defaultRegion is used.
+func NewOrganization(ctx context.Context, profile, roleName string) (*Organization, error) {
+	client, err := aws.NewClient(ctx, centralConfig(profile)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client (profile=%s): %s", profile, err)
+	}
+
+	if client.Region == "" {
+		client, err = aws.NewClient(ctx, append(centralConfig(profile), config.WithRegion(defaultRegion))...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create client (profile=%s): %s", profile, err)
+		}
+	}
+
+	client.Profile = profile
+
+	return NewOrganizationWithClient(*client, profile, roleName), nil
+}
+
+// NewOrganizationWithClient creates an organization whose member accounts are accessed by assuming the role
+// with the given name via the given client of the central account (created for the given profile).
+func NewOrganizationWithClient(client aws.Client, profile, roleName string) *Organization {
+	region := client.Region
+	if region == "" {
+		region = defaultRegion
+	}
+
+	return &Organization{
+		client:      client,
+		profile:     profile,
+		roleName:    roleName,
+		region:      region,
+		credentials: map[string]*awssdk.CredentialsCache{},
+	}
+}
+
+// centralConfig returns the config of the central account's profile (or the default credential provider chain
+// if empty).
+func centralConfig(profile string) []func(*config.LoadOptions) error {
+	if profile == "" {
+		return nil
+	}
+
+	return []func(*config.LoadOptions) error{config.WithSharedConfigProfile(profile)}
+}
+
+// OrganizationsAPI is the part of the Organizations API used to list member accounts.
+type OrganizationsAPI interface {
+	organizations.ListAccountsAPIClient
+	organizations.ListAccountsForParentAPIClient
+	organizations.ListOrganizationalUnitsForParentAPIClient
+}
+
+// ListAccounts lists the active member accounts of the organization (see ListMemberAccounts).
+func (o *Organization) ListAccounts(ctx context.Context, ou, pattern string) ([]MemberAccount, error) {
+	return ListMemberAccounts(ctx, o.client.Organizationsconn, ou, pattern)
+}
+
+// ListMemberAccounts lists the active member accounts of an organization sorted by ID. If ou is set, only
+// the accounts within the organizational unit (including nested ones) are listed. If pattern is set, only accounts
+// whose ID or name match the regex are listed.
+func ListMemberAccounts(ctx context.Context, api OrganizationsAPI, ou, pattern string) ([]MemberAccount, error) {
+	var accountFilter *regexp.Regexp
+
+	if pattern != "" {
+		var err error
+
+		accountFilter, err = regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid account filter: %s", err)
+		}
+	}
+
+	var accounts []types.Account
+	var err error
+
+	if ou == "" {
+		accounts, err = listAllAccounts(ctx, api)
+	} else {
+		accounts, err = listAccountsForParent(ctx, api, ou)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var result []MemberAccount
+
+	for _, a := range accounts {
+		if a.Status != types.AccountStatusActive || a.Id == nil {
+			continue
+		}
+
+		account := MemberAccount{ID: *a.Id}
+		if a.Name != nil {
+			account.Name = *a.Name
+		}
+
+		if accountFilter != nil && !accountFilter.MatchString(account.ID) && !accountFilter.MatchString(account.Name) {
+			continue
+		}
+
+		result = append(result, account)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+
+	return result, nil
+}
+
+func listAllAccounts(ctx context.Context, api OrganizationsAPI) ([]types.Account, error) {
+	var result []types.Account
+
+	pg := organizations.NewListAccountsPaginator(api, &organizations.ListAccountsInput{})
+	for pg.HasMorePages() {
+		page, err := pg.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list accounts of organization: %s", err)
+		}
+
+		result = append(result, page.Accounts...)
+	}
+
+	return result, nil
+}
+
+// listAccountsForParent lists the accounts of an organizational unit and all its nested organizational units.
+func listAccountsForParent(ctx context.Context, api OrganizationsAPI, parentID string) ([]types.Account, error) {
+	var result []types.Account
+
+	pg := organizations.NewListAccountsForParentPaginator(api,
+		&organizations.ListAccountsForParentInput{ParentId: &parentID})
+	for pg.HasMorePages() {
+		page, err := pg.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list accounts of organizational unit (id=%s): %s", parentID, err)
+		}
+
+		result = append(result, page.Accounts...)
+	}
+
+	ouPg := organizations.NewListOrganizationalUnitsForParentPaginator(api,
+		&organizations.ListOrganizationalUnitsForParentInput{ParentId: &parentID})
+	for ouPg.HasMorePages() {
+		page, err := ouPg.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list organizational units (parent=%s): %s", parentID, err)
+		}
+
+		for _, ou := range page.OrganizationalUnits {
+			accounts, err := listAccountsForParent(ctx, api, *ou.Id)
+			if err != nil {
+				return nil, err
+			}
+
+			result = append(result, accounts...)
+		}
+	}
+
+	return result, nil
+}
+
+// AccountNames are the names of member accounts by account ID. Clients of member accounts are keyed by account ID,
+// since account names are not unique, but filters can match accounts by name (see TypeFilter.Account).
+type AccountNames map[string]string
+
+// NewAccountNames returns the names of the given member accounts.
+func NewAccountNames(accounts []MemberAccount) AccountNames {
+	result := AccountNames{}
+
+	for _, a := range accounts {
+		result[a.ID] = a.Name
+	}
+
+	return result
+}
+
+// NewClientPool creates an AWS client for each permutation of the given member accounts and regions,
+// where the profile of a client key is the ID of the account. If no regions are given, the region of the central
+// account is used. If allRegions is set, a client is created for each region that is enabled in a member account
+// instead.
+func (o *Organization) NewClientPool(ctx context.Context, accounts []MemberAccount, regions []string,
+	allRegions bool) (map[aws.ClientKey]aws.Client, error) {
+	if allRegions && len(regions) > 0 {
+		return nil, fmt.Errorf("regions cannot be set when sweeping all regions")
+	}
+
+	result := map[aws.ClientKey]aws.Client{}
+
+	for _, account := range accounts {
+		accountRegions := regions
+
+		if allRegions {
+			client, err := o.newClient(ctx, account, o.region)
+			if err != nil {
+				return nil, err
+			}
+
+			accountRegions, err = EnabledRegions(ctx, *client)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if len(accountRegions) == 0 {
+			accountRegions = []string{o.region}
+		}
+
+		for _, region := range accountRegions {
+			client, err := o.newClient(ctx, account, region)
+			if err != nil {
+				return nil, err
+			}
+
+			result[aws.ClientKey{Profile: client.Profile, Region: client.Region}] = *client
+		}
+	}
+
+	return result, nil
+}
+
+func (o *Organization) newClient(ctx context.Context, account MemberAccount, region string) (*aws.Client, error) {
+	client, err := aws.NewClient(ctx, o.memberConfig(account.ID, region)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client for account (id=%s, name=%s): %s",
+			account.ID, account.Name, err)
+	}
+
+	client.Profile = account.ID
+	client.AccountID = account.ID
+
+	return client, nil
+}
+
+// memberConfig returns the config of a member account in the given region, whose credentials are the ones
+// of the assumed role in that account.
+func (o *Organization) memberConfig(accountID, region string) []func(*config.LoadOptions) error {
+	return append(centralConfig(o.profile),
+		config.WithCredentialsProvider(o.credentialsFor(accountID)),
+		config.WithRegion(region))
+}
+
+// roleSessionName is the name of the sessions of the roles assumed in member accounts.
+const roleSessionName = "awsweeper"
+
+// roleARN returns the ARN of the role to assume in the given member account.
+func (o *Organization) roleARN(accountID string) string {
+	return fmt.Sprintf("arn:aws:iam::%s:role/%s", accountID, o.roleName)
+}
+
+// credentialsFor returns the cached credentials of the assumed role in the given member account.
+func (o *Organization) credentialsFor(accountID string) *awssdk.CredentialsCache {
+	o.credentialsLock.Lock()
+	defer o.credentialsLock.Unlock()
+
+	if c, ok := o.credentials[accountID]; ok {
+		return c
+	}
+
+	c := awssdk.NewCredentialsCache(stscreds.NewAssumeRoleProvider(o.client.Stsconn, o.roleARN(accountID),
+		func(options *stscreds.AssumeRoleOptions) {
+			options.RoleSessionName = roleSessionName
+		}))
+
+	o.credentials[accountID] = c
+
+	return c
+}
+
+// NewProviderPool launches a Terraform AWS Provider for each of the given client keys, which maps to the ID of
+// the member account. Each provider assumes the role in that account itself with the credentials of the central
+// account, so that the credentials of the assumed role are refreshed when they expire during a long run.
+func (o *Organization) NewProviderPool(ctx context.Context, accountIDs map[aws.ClientKey]string,
+	version, installDir string, timeout time.Duration) (map[aws.ClientKey]provider.TerraformProvider, error) {
+	metaPlugin, err := provider.Install("aws", version, installDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to install provider (%s): %s", "aws", err)
+	}
+
+	result := map[aws.ClientKey]provider.TerraformProvider{}
+
+	closeAll := func() {
+		for _, p := range result {
+			_ = p.Close()
+		}
+	}
+
+	for key, cfg := range o.ProviderConfigs(accountIDs) {
+		select {
+		case <-ctx.Done():
+			closeAll()
+			return nil, ctx.Err()
+		default:
+		}
+
+		accountID := accountIDs[key]
+
+		log.WithFields(log.Fields{
+			"account": accountID,
+			"region":  key.Region,
+		}).Debugf("start launching new instance of Terraform AWS Provider")
+
+		pr, err := provider.Launch(metaPlugin.Path, timeout)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("failed to launch provider (%s): %s", metaPlugin.Path, err)
+		}
+
+		err = pr.Configure(cfg)
+		if err != nil {
+			pr.Close()
+			closeAll()
+
+			return nil, fmt.Errorf("failed to configure provider (name=%s, version=%s) for account (id=%s): %s",
+				metaPlugin.Name, metaPlugin.Version, accountID, err)
+		}
+
+		result[key] = *pr
+	}
+
+	return result, nil
+}
+
+// NewTaggingClients creates a Resource Groups Tagging API client for each of the given client keys, which maps to
+// the ID of the member account, with the credentials of the assumed role in that account.
+func (o *Organization) NewTaggingClients(ctx context.Context,
+	accountIDs map[aws.ClientKey]string) (map[aws.ClientKey]TaggingAPI, error) {
+	result := map[aws.ClientKey]TaggingAPI{}
+
+	for key, accountID := range accountIDs {
+		cfg, err := config.LoadDefaultConfig(ctx, o.memberConfig(accountID, key.Region)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create tagging client for account (id=%s): %s", accountID, err)
+		}
+
+		result[key] = resourcegroupstaggingapi.NewFromConfig(cfg)
+	}
+
+	return result, nil
+}
+
+// ProviderConfigs returns the configuration of a Terraform AWS Provider for each of the given client keys, which
+// maps to the ID of the member account. A key without region gets the region of the central account.
+func (o *Organization) ProviderConfigs(accountIDs map[aws.ClientKey]string) map[aws.ClientKey]cty.Value {
+	result := map[aws.ClientKey]cty.Value{}
+
+	for key, accountID := range accountIDs {
+		region := key.Region
+		if region == "" {
+			region = o.region
+		}
+
+		result[key] = providerConfig(region, o.profile, o.roleARN(accountID))
+	}
+
+	return result
+}
+
+// providerConfig returns the configuration of a Terraform AWS Provider for the given region, which assumes
+// the given role with the credentials of the given profile (or the default credential provider chain if empty).
+func providerConfig(region, profile, roleARN string) cty.Value {
+	profileValue := cty.UnknownVal(cty.DynamicPseudoType)
+	if profile != "" {
+		profileValue = cty.StringVal(profile)
+	}
+
+	return cty.ObjectVal(map[string]cty.Value{
+		"region":  cty.StringVal(region),
+		"profile": profileValue,
+		"assume_role": cty.ListVal([]cty.Value{cty.ObjectVal(map[string]cty.Value{
+			"role_arn":            cty.StringVal(roleARN),
+			"session_name":        cty.StringVal(roleSessionName),
+			"duration_seconds":    cty.NullVal(cty.Number),
+			"external_id":         cty.NullVal(cty.String),
+			"policy":              cty.NullVal(cty.String),
+			"policy_arns":         cty.NullVal(cty.Set(cty.String)),
+			"tags":                cty.NullVal(cty.Map(cty.String)),
+			"transitive_tag_keys": cty.NullVal(cty.Set(cty.String)),
+		})}),
+		"access_key":                  cty.UnknownVal(cty.DynamicPseudoType),
+		"secret_key":                  cty.UnknownVal(cty.DynamicPseudoType),
+		"token":                       cty.UnknownVal(cty.DynamicPseudoType),
+		"allowed_account_ids":         cty.UnknownVal(cty.DynamicPseudoType),
+		"default_tags":                cty.UnknownVal(cty.DynamicPseudoType),
+		"endpoints":                   cty.UnknownVal(cty.DynamicPseudoType),
+		"forbidden_account_ids":       cty.UnknownVal(cty.DynamicPseudoType),
+		"ignore_tag_prefixes":         cty.UnknownVal(cty.DynamicPseudoType),
+		"ignore_tags":                 cty.UnknownVal(cty.DynamicPseudoType),
+		"insecure":                    cty.UnknownVal(cty.DynamicPseudoType),
+		"max_retries":                 cty.UnknownVal(cty.DynamicPseudoType),
+		"s3_force_path_style":         cty.UnknownVal(cty.DynamicPseudoType),
+		"shared_credentials_file":     cty.UnknownVal(cty.DynamicPseudoType),
+		"skip_credentials_validation": cty.UnknownVal(cty.DynamicPseudoType),
+		"skip_get_ec2_platforms":      cty.UnknownVal(cty.DynamicPseudoType),
+		"skip_metadata_api_check":     cty.UnknownVal(cty.DynamicPseudoType),
+		"skip_region_validation":      cty.UnknownVal(cty.DynamicPseudoType),
+		"skip_requesting_account_id":  cty.UnknownVal(cty.DynamicPseudoType),
+	})
+}
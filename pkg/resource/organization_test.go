@@ -0,0 +1,214 @@
+package resource_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/jckuester/awstools-lib/aws"
+	"github.com/jckuester/awsweeper/pkg/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// fakeOrganizations has accounts and organizational units by the ID of their parent (the root is "r-1").
+// All accounts are returned in pages of one account.
+type fakeOrganizations struct {
+	accounts map[string][]types.Account
+	ous      map[string][]string
+	err      error
+}
+
+func (f fakeOrganizations) ListAccounts(_ context.Context, params *organizations.ListAccountsInput,
+	_ ...func(*organizations.Options)) (*organizations.ListAccountsOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	var all []types.Account
+	for _, parent := range []string{"r-1", "ou-1", "ou-2", "ou-3"} {
+		all = append(all, f.accounts[parent]...)
+	}
+
+	i := 0
+	if params.NextToken != nil {
+		i, _ = strconv.Atoi(*params.NextToken)
+	}
+
+	output := &organizations.ListAccountsOutput{Accounts: all[i : i+1]}
+	if i+1 < len(all) {
+		output.NextToken = awssdk.String(strconv.Itoa(i + 1))
+	}
+
+	return output, nil
+}
+
+func (f fakeOrganizations) ListAccountsForParent(_ context.Context, params *organizations.ListAccountsForParentInput,
+	_ ...func(*organizations.Options)) (*organizations.ListAccountsForParentOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	return &organizations.ListAccountsForParentOutput{Accounts: f.accounts[*params.ParentId]}, nil
+}
+
+func (f fakeOrganizations) ListOrganizationalUnitsForParent(_ context.Context,
+	params *organizations.ListOrganizationalUnitsForParentInput,
+	_ ...func(*organizations.Options)) (*organizations.ListOrganizationalUnitsForParentOutput, error) {
+	var output organizations.ListOrganizationalUnitsForParentOutput
+
+	for _, id := range f.ous[*params.ParentId] {
+		output.OrganizationalUnits = append(output.OrganizationalUnits, types.OrganizationalUnit{Id: awssdk.String(id)})
+	}
+
+	return &output, nil
+}
+
+func account(id, name string, status types.AccountStatus) types.Account {
+	return types.Account{Id: awssdk.String(id), Name: awssdk.String(name), Status: status}
+}
+
+func TestListMemberAccounts(t *testing.T) {
+	api := fakeOrganizations{
+		accounts: map[string][]types.Account{
+			"r-1":  {account("444444444444", "management", types.AccountStatusActive)},
+			"ou-1": {account("333333333333", "sandbox-a", types.AccountStatusActive)},
+			"ou-2": {
+				account("222222222222", "sandbox-b", types.AccountStatusActive),
+				account("555555555555", "sandbox-closed", types.AccountStatusSuspended),
+			},
+			"ou-3": {account("111111111111", "prod", types.AccountStatusActive)},
+		},
+		// ou-2 is nested in ou-1
+		ous: map[string][]string{
+			"r-1":  {"ou-1", "ou-3"},
+			"ou-1": {"ou-2"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		ou      string
+		pattern string
+		want    []resource.MemberAccount
+	}{
+		{
+			name: "all accounts",
+			want: []resource.MemberAccount{
+				{ID: "111111111111", Name: "prod"},
+				{ID: "222222222222", Name: "sandbox-b"},
+				{ID: "333333333333", Name: "sandbox-a"},
+				{ID: "444444444444", Name: "management"},
+			},
+		},
+		{
+			name: "accounts of organizational unit including nested ones",
+			ou:   "ou-1",
+			want: []resource.MemberAccount{
+				{ID: "222222222222", Name: "sandbox-b"},
+				{ID: "333333333333", Name: "sandbox-a"},
+			},
+		},
+		{
+			name:    "accounts matching name",
+			pattern: "^sandbox-",
+			want: []resource.MemberAccount{
+				{ID: "222222222222", Name: "sandbox-b"},
+				{ID: "333333333333", Name: "sandbox-a"},
+			},
+		},
+		{
+			name:    "accounts matching ID within organizational unit",
+			ou:      "r-1",
+			pattern: "^(1|4)",
+			want: []resource.MemberAccount{
+				{ID: "111111111111", Name: "prod"},
+				{ID: "444444444444", Name: "management"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accounts, err := resource.ListMemberAccounts(context.Background(), api, tt.ou, tt.pattern)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.want, accounts)
+		})
+	}
+}
+
+func TestListMemberAccounts_Errors(t *testing.T) {
+	_, err := resource.ListMemberAccounts(context.Background(), fakeOrganizations{}, "", "(")
+	assert.EqualError(t, err, "invalid account filter: error parsing regexp: missing closing ): `(`")
+
+	api := fakeOrganizations{err: errors.New("AccessDenied")}
+
+	_, err = resource.ListMemberAccounts(context.Background(), api, "", "")
+	assert.EqualError(t, err, "failed to list accounts of organization: AccessDenied")
+
+	_, err = resource.ListMemberAccounts(context.Background(), api, "ou-1", "")
+	assert.EqualError(t, err, "failed to list accounts of organizational unit (id=ou-1): AccessDenied")
+}
+
+func TestOrganization_NewClientPool(t *testing.T) {
+	accounts := []resource.MemberAccount{{ID: "111111111111", Name: "a"}, {ID: "222222222222", Name: "b"}}
+
+	// the central profile has no region
+	org := resource.NewOrganizationWithClient(aws.Client{}, "", "Sweeper")
+
+	clients, err := org.NewClientPool(context.Background(), accounts, nil, false)
+	require.NoError(t, err)
+
+	assert.Len(t, clients, 2)
+	for _, key := range []aws.ClientKey{
+		{Profile: "111111111111", Region: "us-east-1"},
+		{Profile: "222222222222", Region: "us-east-1"},
+	} {
+		require.Contains(t, clients, key)
+		assert.Equal(t, key.Profile, clients[key].AccountID)
+	}
+
+	clients, err = org.NewClientPool(context.Background(), accounts[:1], []string{"eu-west-1", "us-west-2"}, false)
+	require.NoError(t, err)
+
+	assert.Len(t, clients, 2)
+	assert.Contains(t, clients, aws.ClientKey{Profile: "111111111111", Region: "eu-west-1"})
+	assert.Contains(t, clients, aws.ClientKey{Profile: "111111111111", Region: "us-west-2"})
+
+	_, err = org.NewClientPool(context.Background(), accounts, []string{"eu-west-1"}, true)
+	assert.EqualError(t, err, "regions cannot be set when sweeping all regions")
+}
+
+func TestOrganization_ProviderConfigs(t *testing.T) {
+	org := resource.NewOrganizationWithClient(aws.Client{Region: "eu-central-1"}, "tooling", "Sweeper")
+
+	configs := org.ProviderConfigs(map[aws.ClientKey]string{
+		{Profile: "111111111111", Region: "us-west-2"}: "111111111111",
+		{Profile: "111111111111", Region: "eu-west-1"}: "111111111111",
+		{Profile: "222222222222", Region: "us-west-2"}: "222222222222",
+		{Profile: "333333333333"}:                      "333333333333",
+	})
+
+	require.Len(t, configs, 4)
+
+	for key, wantRegion := range map[aws.ClientKey]string{
+		{Profile: "111111111111", Region: "us-west-2"}: "us-west-2",
+		{Profile: "111111111111", Region: "eu-west-1"}: "eu-west-1",
+		{Profile: "222222222222", Region: "us-west-2"}: "us-west-2",
+		{Profile: "333333333333"}:                      "eu-central-1",
+	} {
+		cfg, ok := configs[key]
+		require.True(t, ok, key)
+
+		assert.Equal(t, cty.StringVal(wantRegion), cfg.GetAttr("region"))
+		assert.Equal(t, cty.StringVal("tooling"), cfg.GetAttr("profile"))
+
+		assumeRole := cfg.GetAttr("assume_role").Index(cty.NumberIntVal(0))
+		assert.Equal(t, cty.StringVal("arn:aws:iam::"+key.Profile+":role/Sweeper"), assumeRole.GetAttr("role_arn"))
+	}
+}
@@ -0,0 +1,203 @@
+package resource
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/jckuester/awstools-lib/aws"
+	"github.com/jckuester/awstools-lib/terraform"
+	"github.com/jckuester/terradozer/pkg/provider"
+	terradozerRes "github.com/jckuester/terradozer/pkg/resource"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// Plan is a persisted list of resources to delete. A plan can be reviewed before it is applied,
+// which deletes exactly the resources in the plan without listing resources again.
+type Plan struct {
+	CreatedAt time.Time `json:"created_at"`
+	// ProviderVersion is the version of the Terraform AWS Provider that was used to create the plan.
+	ProviderVersion string            `json:"provider_version"`
+	Resources       []PlannedResource `json:"resources"`
+}
+
+// PlannedResource is a resource in a plan.
+type PlannedResource struct {
+	Type      string            `json:"type"`
+	ID        string            `json:"id"`
+	AccountID string            `json:"account_id"`
+	Profile   string            `json:"profile"`
+	Region    string            `json:"region"`
+	Tags      map[string]string `json:"tags,omitempty"`
+	CreatedAt *time.Time        `json:"created_at,omitempty"`
+	// StateType is the type of the Terraform state, which is needed to decode the state.
+	StateType json.RawMessage `json:"state_type"`
+	State     json.RawMessage `json:"state"`
+}
+
+// NewPlan creates a plan to delete the given resources. Resources that are not Terraform resources
+// (see APIResource) cannot be applied without listing them again, and their parents cannot be deleted without them
+// (e.g., an ECR repository with images), so a plan cannot be created if there are any.
+func NewPlan(resources []terraform.Resource, providerVersion string) (*Plan, error) {
+	var apiResources []terraform.Resource
+
+	for _, r := range resources {
+		if _, ok := r.UpdatableResource.(*APIResource); ok {
+			apiResources = append(apiResources, r)
+		}
+	}
+
+	if len(apiResources) > 0 {
+		return nil, fmt.Errorf("%d resources are deleted via the AWS API and cannot be part of a plan "+
+			"(e.g., type=%s, id=%s)", len(apiResources), apiResources[0].Type, apiResources[0].ID)
+	}
+
+	plan := &Plan{
+		CreatedAt:       time.Now().UTC(),
+		ProviderVersion: providerVersion,
+		Resources:       []PlannedResource{},
+	}
+
+	for _, r := range resources {
+		if r.UpdatableResource == nil || r.State() == nil {
+			return nil, fmt.Errorf("state of resource is nil (type=%s, id=%s)", r.Type, r.ID)
+		}
+
+		state := *r.State()
+
+		stateType, err := ctyjson.MarshalType(state.Type())
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal state type (type=%s, id=%s): %s", r.Type, r.ID, err)
+		}
+
+		stateValue, err := ctyjson.Marshal(state, state.Type())
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal state (type=%s, id=%s): %s", r.Type, r.ID, err)
+		}
+
+		plan.Resources = append(plan.Resources, PlannedResource{
+			Type:      r.Type,
+			ID:        r.ID,
+			AccountID: r.AccountID,
+			Profile:   r.Profile,
+			Region:    r.Region,
+			Tags:      r.Tags,
+			CreatedAt: r.CreatedAt,
+			StateType: stateType,
+			State:     stateValue,
+		})
+	}
+
+	return plan, nil
+}
+
+// ReadPlan reads a plan from a file.
+func ReadPlan(path string) (*Plan, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan Plan
+
+	err = json.Unmarshal(data, &plan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse plan: %s", err)
+	}
+
+	return &plan, nil
+}
+
+// Write writes the plan to a file.
+func (p Plan) Write(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+// Validate checks that the plan is not older than the given maximum age and
+// was created with the given version of the Terraform AWS Provider.
+func (p Plan) Validate(maxAge time.Duration, providerVersion string) error {
+	if p.ProviderVersion != providerVersion {
+		return fmt.Errorf("plan was created with a different provider version (plan: %s, current: %s)",
+			p.ProviderVersion, providerVersion)
+	}
+
+	age := time.Since(p.CreatedAt)
+	if age > maxAge {
+		return fmt.Errorf("plan is older than %s (created at: %s)", maxAge, p.CreatedAt)
+	}
+
+	return nil
+}
+
+// ClientKeys returns the combinations of AWS profile and region of all resources in the plan.
+func (p Plan) ClientKeys() []aws.ClientKey {
+	var result []aws.ClientKey
+
+	seen := map[aws.ClientKey]bool{}
+
+	for _, r := range p.Resources {
+		key := aws.ClientKey{Profile: r.Profile, Region: r.Region}
+		if seen[key] {
+			continue
+		}
+
+		seen[key] = true
+		result = append(result, key)
+	}
+
+	return result
+}
+
+// AccountIDs returns the IDs of the accounts of all resources in the plan
+// by their combination of AWS profile and region.
+func (p Plan) AccountIDs() map[aws.ClientKey]string {
+	result := map[aws.ClientKey]string{}
+
+	for _, r := range p.Resources {
+		result[aws.ClientKey{Profile: r.Profile, Region: r.Region}] = r.AccountID
+	}
+
+	return result
+}
+
+// TerraformResources returns the resources in the plan including their Terraform state,
+// where each resource is destroyed by the provider matching its profile and region.
+func (p Plan) TerraformResources(providers map[aws.ClientKey]provider.TerraformProvider) ([]terraform.Resource, error) {
+	var result []terraform.Resource
+
+	for _, r := range p.Resources {
+		stateType, err := ctyjson.UnmarshalType(r.StateType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal state type (type=%s, id=%s): %s", r.Type, r.ID, err)
+		}
+
+		state, err := ctyjson.Unmarshal(r.State, stateType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal state (type=%s, id=%s): %s", r.Type, r.ID, err)
+		}
+
+		pr, ok := providers[aws.ClientKey{Profile: r.Profile, Region: r.Region}]
+		if !ok {
+			return nil, fmt.Errorf("no provider found for profile=%s, region=%s", r.Profile, r.Region)
+		}
+
+		result = append(result, terraform.Resource{
+			Type:              r.Type,
+			ID:                r.ID,
+			Region:            r.Region,
+			Profile:           r.Profile,
+			AccountID:         r.AccountID,
+			Tags:              r.Tags,
+			CreatedAt:         r.CreatedAt,
+			UpdatableResource: terradozerRes.NewWithState(r.Type, r.ID, &pr, &state),
+		})
+	}
+
+	return result, nil
+}
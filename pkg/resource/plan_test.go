@@ -0,0 +1,120 @@
+package resource_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awstools "github.com/jckuester/awstools-lib/aws"
+	"github.com/jckuester/awstools-lib/terraform"
+	"github.com/jckuester/awsweeper/pkg/resource"
+	"github.com/jckuester/terradozer/pkg/provider"
+	terradozerRes "github.com/jckuester/terradozer/pkg/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestPlan_WriteAndRead(t *testing.T) {
+	dir, err := ioutil.TempDir("", "awsweeper")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	state := cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.StringVal("vpc-1"),
+		"tags": cty.MapVal(map[string]cty.Value{"foo": cty.StringVal("bar")}),
+	})
+
+	res := []terraform.Resource{
+		{
+			Type:              "aws_vpc",
+			ID:                "vpc-1",
+			Region:            "us-west-2",
+			Profile:           "myaccount",
+			AccountID:         "123456789012",
+			Tags:              map[string]string{"foo": "bar"},
+			CreatedAt:         aws.Time(time.Date(2018, 11, 17, 5, 0, 0, 0, time.UTC)),
+			UpdatableResource: terradozerRes.NewWithState("aws_vpc", "vpc-1", nil, &state),
+		},
+	}
+
+	plan, err := resource.NewPlan(res, "v3.42.0")
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "plan.json")
+
+	err = plan.Write(path)
+	require.NoError(t, err)
+
+	actualPlan, err := resource.ReadPlan(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, []awstools.ClientKey{{Profile: "myaccount", Region: "us-west-2"}}, actualPlan.ClientKeys())
+	assert.Equal(t, map[awstools.ClientKey]string{
+		{Profile: "myaccount", Region: "us-west-2"}: "123456789012",
+	}, actualPlan.AccountIDs())
+
+	actualRes, err := actualPlan.TerraformResources(map[awstools.ClientKey]provider.TerraformProvider{
+		{Profile: "myaccount", Region: "us-west-2"}: {},
+	})
+	require.NoError(t, err)
+	require.Len(t, actualRes, 1)
+
+	assert.Equal(t, res[0].Type, actualRes[0].Type)
+	assert.Equal(t, res[0].ID, actualRes[0].ID)
+	assert.Equal(t, res[0].Region, actualRes[0].Region)
+	assert.Equal(t, res[0].Profile, actualRes[0].Profile)
+	assert.Equal(t, res[0].AccountID, actualRes[0].AccountID)
+	assert.Equal(t, res[0].Tags, actualRes[0].Tags)
+	assert.True(t, res[0].CreatedAt.Equal(*actualRes[0].CreatedAt))
+	assert.True(t, state.RawEquals(*actualRes[0].State()))
+
+	_, err = actualPlan.TerraformResources(map[awstools.ClientKey]provider.TerraformProvider{})
+	assert.EqualError(t, err, "no provider found for profile=myaccount, region=us-west-2")
+}
+
+func TestPlan_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		plan    resource.Plan
+		wantErr string
+	}{
+		{
+			name: "valid plan",
+			plan: resource.Plan{
+				CreatedAt:       time.Now().Add(-time.Hour),
+				ProviderVersion: "v3.42.0",
+			},
+		},
+		{
+			name: "plan too old",
+			plan: resource.Plan{
+				CreatedAt:       time.Date(2018, 11, 17, 5, 0, 0, 0, time.UTC),
+				ProviderVersion: "v3.42.0",
+			},
+			wantErr: "plan is older than 24h0m0s (created at: 2018-11-17 05:00:00 +0000 UTC)",
+		},
+		{
+			name: "different provider version",
+			plan: resource.Plan{
+				CreatedAt:       time.Now(),
+				ProviderVersion: "v3.41.0",
+			},
+			wantErr: "plan was created with a different provider version (plan: v3.41.0, current: v3.42.0)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.plan.Validate(24*time.Hour, "v3.42.0")
+
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package resource
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/apex/log"
+	awslsRes "github.com/jckuester/awsls/resource"
+	"github.com/jckuester/awstools-lib/terraform"
+	"gopkg.in/yaml.v3"
+)
+
+// Protection is a list of rules describing resources that must never be deleted.
+type Protection []ProtectRule
+
+// ProtectRule describes resources that must never be deleted. All criteria of a rule must match.
+type ProtectRule struct {
+	// Type is the Terraform type of protected resources.
+	Type string                  `yaml:",omitempty"`
+	ID   *StringFilter           `yaml:",omitempty"`
+	ARN  *StringFilter           `yaml:"arn,omitempty"`
+	Tags map[string]StringFilter `yaml:",omitempty"`
+}
+
+// NewProtection reads the protect section of a given path to a yaml file.
+func NewProtection(path string) (Protection, error) {
+	var content struct {
+		Protect Protection
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+
+	err = dec.Decode(&content)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return content.Protect, nil
+}
+
+// Validate checks that every rule has at least one criterion, so that a rule never protects all resources
+// (or none by accident), that its type is supported, and that all regular expressions of the rules compile.
+func (p Protection) Validate() error {
+	for i, rule := range p {
+		if rule.Type == "" && rule.ID == nil && rule.ARN == nil && len(rule.Tags) == 0 {
+			return fmt.Errorf("protect rule %d has no criteria", i+1)
+		}
+
+		err := rule.validate()
+		if err != nil {
+			return fmt.Errorf("invalid protect rule %d: %s", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func (p ProtectRule) validate() error {
+	if p.Type != "" && !awslsRes.IsSupportedType(p.Type) && !isChildType(p.Type) {
+		return fmt.Errorf("unsupported resource type: %s", p.Type)
+	}
+
+	if p.ID != nil {
+		if err := p.ID.validate(); err != nil {
+			return fmt.Errorf("id: %s", err)
+		}
+	}
+
+	if p.ARN != nil {
+		if err := p.ARN.validate(); err != nil {
+			return fmt.Errorf("arn: %s", err)
+		}
+	}
+
+	return validateTagFilters(p.Tags)
+}
+
+// Apply splits the given resources into the ones that are not protected and the ones that are protected.
+func (p Protection) Apply(res []terraform.Resource) ([]terraform.Resource, []terraform.Resource) {
+	var unprotected, protected []terraform.Resource
+
+	for _, r := range res {
+		if p.Match(r) {
+			protected = append(protected, r)
+		} else {
+			unprotected = append(unprotected, r)
+		}
+	}
+
+	return unprotected, protected
+}
+
+// Match checks whether a resource is protected by any of the rules.
+func (p Protection) Match(r terraform.Resource) bool {
+	for _, rule := range p {
+		if rule.Match(r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Match checks whether a resource is protected by the rule. If the rule has tag criteria, but the tags of
+// the resource are unknown (see tagsOf), the resource is protected as long as all other criteria match.
+func (p ProtectRule) Match(r terraform.Resource) bool {
+	if p.Type != "" && p.Type != r.Type {
+		return false
+	}
+
+	tf := TypeFilter{ID: p.ID, Tags: p.Tags}
+	if !tf.matchID(r.ID) {
+		return false
+	}
+
+	if p.ARN != nil {
+		arn, err := getARN(r)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"type": r.Type,
+				"id":   r.ID,
+			}).WithError(err).Debug("failed to get ARN")
+
+			return false
+		}
+
+		match, err := p.ARN.matches(arn)
+		if err != nil {
+			log.WithError(err).Error("failed to match ARN")
+			return false
+		}
+
+		if !match {
+			return false
+		}
+	}
+
+	if len(p.Tags) == 0 {
+		return true
+	}
+
+	tags, ok := tagsOf(r)
+	if !ok {
+		log.WithFields(log.Fields{
+			"type": r.Type,
+			"id":   r.ID,
+		}).Debug("protected, since tags are unknown")
+
+		return true
+	}
+
+	return tf.MatchTags(tags)
+}
+
+// tagsOf returns the tags of a resource, which are either already set or read from its state. The tags are
+// unknown (i.e., ok is false) if they cannot be read for a type that supports tags, e.g. because the state
+// of the resource couldn't be updated.
+func tagsOf(r terraform.Resource) (tags map[string]string, ok bool) {
+	if r.Tags != nil {
+		return r.Tags, true
+	}
+
+	tags, err := GetTags(&r)
+	if err == nil || errors.Is(err, errNullValue) || errors.Is(err, errAttributeNotFound) {
+		return tags, true
+	}
+
+	return nil, !awslsRes.SupportsTags(r.Type)
+}
+
+// getARN returns the ARN of a resource, which is either its arn attribute or its ID.
+func getARN(r terraform.Resource) (string, error) {
+	arn, err := GetAttribute(&r, "arn")
+	if err == nil {
+		return arn, nil
+	}
+
+	if strings.HasPrefix(r.ID, "arn:") {
+		return r.ID, nil
+	}
+
+	return "", errors.New("resource has no ARN")
+}
@@ -0,0 +1,91 @@
+package resource_test
+
+import (
+	"testing"
+
+	"github.com/jckuester/awstools-lib/terraform"
+	"github.com/jckuester/awsweeper/pkg/resource"
+	terradozerRes "github.com/jckuester/terradozer/pkg/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestProtection_Apply(t *testing.T) {
+	p := resource.Protection{
+		{Type: "aws_s3_bucket", ID: &resource.StringFilter{Pattern: "^terraform-state$"}},
+		{ARN: &resource.StringFilter{Pattern: "^arn:aws:iam::123456789012:role/ci$"}},
+		{Type: "aws_vpc", Tags: map[string]resource.StringFilter{"Name": {Pattern: "^bastion$"}}},
+	}
+
+	roleState := cty.ObjectVal(map[string]cty.Value{
+		"arn": cty.StringVal("arn:aws:iam::123456789012:role/ci"),
+	})
+
+	res := []terraform.Resource{
+		{Type: "aws_s3_bucket", ID: "terraform-state"},
+		{Type: "aws_s3_bucket", ID: "terraform-state-old"},
+		{
+			Type:              "aws_iam_role",
+			ID:                "ci",
+			UpdatableResource: terradozerRes.NewWithState("aws_iam_role", "ci", nil, &roleState),
+		},
+		{Type: "aws_iam_role", ID: "other"},
+		{Type: "aws_iam_policy", ID: "arn:aws:iam::123456789012:role/ci"},
+		{Type: "aws_vpc", ID: "vpc-1", Tags: map[string]string{"Name": "bastion"}},
+		{Type: "aws_vpc", ID: "vpc-2", Tags: map[string]string{"Name": "app"}},
+	}
+
+	unprotected, protected := p.Apply(res)
+
+	assert.Equal(t, []string{"terraform-state-old", "other", "vpc-2"}, ids(unprotected))
+	assert.Equal(t, []string{"terraform-state", "ci", "arn:aws:iam::123456789012:role/ci", "vpc-1"}, ids(protected))
+}
+
+func TestProtection_Apply_UnknownTags(t *testing.T) {
+	p := resource.Protection{
+		{Tags: map[string]resource.StringFilter{"keep": {Pattern: "^true$"}}},
+	}
+
+	newResource := func(rType, id string, tags cty.Value) terraform.Resource {
+		state := cty.ObjectVal(map[string]cty.Value{"id": cty.StringVal(id), "tags": tags})
+
+		return terraform.Resource{Type: rType, ID: id,
+			UpdatableResource: terradozerRes.NewWithState(rType, id, nil, &state)}
+	}
+
+	res := []terraform.Resource{
+		// tags are read from the state (e.g., of dependent resources, whose tags are not set)
+		newResource("aws_instance", "i-1", cty.MapVal(map[string]cty.Value{"keep": cty.StringVal("true")})),
+		newResource("aws_instance", "i-2", cty.MapVal(map[string]cty.Value{"keep": cty.StringVal("false")})),
+		newResource("aws_instance", "i-3", cty.NullVal(cty.Map(cty.String))),
+		// the state couldn't be read
+		{Type: "aws_instance", ID: "i-4", UpdatableResource: terradozerRes.New("aws_instance", "i-4", nil, nil)},
+		{Type: "aws_instance", ID: "i-5"},
+		// the type doesn't support tags
+		{Type: "aws_iam_user_policy_attachment", ID: "attachment"},
+	}
+
+	unprotected, protected := p.Apply(res)
+
+	assert.Equal(t, []string{"i-2", "i-3", "attachment"}, ids(unprotected))
+	assert.Equal(t, []string{"i-1", "i-4", "i-5"}, ids(protected))
+}
+
+func TestProtection_Validate(t *testing.T) {
+	assert.NoError(t, resource.Protection{{Type: "aws_vpc"}}.Validate())
+	assert.NoError(t, resource.Protection{{Type: "aws_ecr_images"}}.Validate())
+	assert.EqualError(t, resource.Protection{{Type: "aws_vcp"}}.Validate(),
+		"invalid protect rule 1: unsupported resource type: aws_vcp")
+	assert.EqualError(t, resource.Protection{{Type: "aws_vpc"}, {}}.Validate(), "protect rule 2 has no criteria")
+	assert.EqualError(t, resource.Protection{{ARN: &resource.StringFilter{Pattern: "*"}}}.Validate(),
+		"invalid protect rule 1: arn: invalid regex: error parsing regexp: missing argument to repetition operator: `*`")
+}
+
+func ids(res []terraform.Resource) []string {
+	var result []string
+	for _, r := range res {
+		result = append(result, r.ID)
+	}
+
+	return result
+}
@@ -0,0 +1,148 @@
+package resource
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/jckuester/awstools-lib/terraform"
+)
+
+// Outcome is what happened to a resource that matched the filter.
+type Outcome string
+
+const (
+	OutcomeDeleted Outcome = "deleted"
+	OutcomeFailed  Outcome = "failed"
+	// OutcomeTimedOut means that the deletion of a resource didn't finish within the timeout.
+	OutcomeTimedOut Outcome = "timed_out"
+	// OutcomeSkipped means that a resource was not deleted, e.g. because of a dry run or missing confirmation.
+	// If a run is aborted during deletion, resources whose outcome is unknown are skipped as well.
+	OutcomeSkipped   Outcome = "skipped"
+	OutcomeProtected Outcome = "protected"
+	OutcomeMarked    Outcome = "marked"
+)
+
+// Report describes every resource that matched the filter and its outcome.
+type Report struct {
+	CreatedAt time.Time        `json:"created_at"`
+	DryRun    bool             `json:"dry_run"`
+	Resources []ReportResource `json:"resources"`
+	Totals    ReportTotals     `json:"totals"`
+
+	// mu guards the report, since a run can be aborted while resources are still being added
+	mu sync.Mutex
+}
+
+// ReportResource is a resource in a report.
+type ReportResource struct {
+	Type      string  `json:"type"`
+	ID        string  `json:"id"`
+	AccountID string  `json:"account_id"`
+	Profile   string  `json:"profile"`
+	Region    string  `json:"region"`
+	Outcome   Outcome `json:"outcome"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Totals is the number of resources per outcome.
+type Totals map[Outcome]int
+
+// ReportTotals is the number of resources per outcome in total, and per type, account and region.
+type ReportTotals struct {
+	All       Totals            `json:"all"`
+	ByType    map[string]Totals `json:"by_type"`
+	ByAccount map[string]Totals `json:"by_account"`
+	ByRegion  map[string]Totals `json:"by_region"`
+}
+
+// NewReport creates an empty report.
+func NewReport(dryRun bool) *Report {
+	return &Report{
+		CreatedAt: time.Now().UTC(),
+		DryRun:    dryRun,
+		Resources: []ReportResource{},
+		Totals: ReportTotals{
+			All:       Totals{},
+			ByType:    map[string]Totals{},
+			ByAccount: map[string]Totals{},
+			ByRegion:  map[string]Totals{},
+		},
+	}
+}
+
+// Add adds resources with the given outcome to the report.
+func (r *Report) Add(outcome Outcome, res ...terraform.Resource) {
+	for _, rs := range res {
+		r.AddWithError(rs, outcome, nil)
+	}
+}
+
+// AddUnreported adds the resources that haven't been added to the report yet with the given outcome.
+func (r *Report) AddUnreported(outcome Outcome, res ...terraform.Resource) {
+	r.mu.Lock()
+	reported := map[ReportResource]bool{}
+	for _, rs := range r.Resources {
+		reported[reportKey(rs.Type, rs.ID, rs.AccountID, rs.Region)] = true
+	}
+	r.mu.Unlock()
+
+	for _, rs := range res {
+		if !reported[reportKey(rs.Type, rs.ID, rs.AccountID, rs.Region)] {
+			r.AddWithError(rs, outcome, nil)
+		}
+	}
+}
+
+// reportKey identifies a resource in a report regardless of its outcome.
+func reportKey(rType, id, accountID, region string) ReportResource {
+	return ReportResource{Type: rType, ID: id, AccountID: accountID, Region: region}
+}
+
+// AddWithError adds a resource with the given outcome and error (can be nil) to the report.
+func (r *Report) AddWithError(res terraform.Resource, outcome Outcome, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reportRes := ReportResource{
+		Type:      res.Type,
+		ID:        res.ID,
+		AccountID: res.AccountID,
+		Profile:   res.Profile,
+		Region:    res.Region,
+		Outcome:   outcome,
+	}
+
+	if err != nil {
+		reportRes.Error = err.Error()
+	}
+
+	r.Resources = append(r.Resources, reportRes)
+
+	r.Totals.All[outcome]++
+	addTotal(r.Totals.ByType, res.Type, outcome)
+	addTotal(r.Totals.ByAccount, res.AccountID, outcome)
+	addTotal(r.Totals.ByRegion, res.Region, outcome)
+}
+
+func addTotal(totals map[string]Totals, key string, outcome Outcome) {
+	if _, ok := totals[key]; !ok {
+		totals[key] = Totals{}
+	}
+
+	totals[key][outcome]++
+}
+
+// Write writes the report as JSON to a file.
+func (r *Report) Write(path string) error {
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r, "", "  ")
+	r.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0600)
+}
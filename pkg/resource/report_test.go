@@ -0,0 +1,86 @@
+package resource_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jckuester/awstools-lib/terraform"
+	"github.com/jckuester/awsweeper/pkg/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReport_Write(t *testing.T) {
+	dir, err := ioutil.TempDir("", "awsweeper")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	report := resource.NewReport(false)
+
+	report.Add(resource.OutcomeDeleted,
+		terraform.Resource{Type: "aws_vpc", ID: "vpc-1", AccountID: "111111111111", Region: "us-west-2"},
+		terraform.Resource{Type: "aws_subnet", ID: "subnet-1", AccountID: "111111111111", Region: "us-west-2"})
+	report.AddWithError(terraform.Resource{Type: "aws_vpc", ID: "vpc-2", AccountID: "222222222222", Region: "eu-west-1"},
+		resource.OutcomeFailed, errors.New("DependencyViolation"))
+	report.Add(resource.OutcomeProtected,
+		terraform.Resource{Type: "aws_vpc", ID: "vpc-3", AccountID: "111111111111", Region: "us-west-2"})
+
+	path := filepath.Join(dir, "report.json")
+
+	err = report.Write(path)
+	require.NoError(t, err)
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	var actual resource.Report
+	err = json.Unmarshal(data, &actual)
+	require.NoError(t, err)
+
+	require.Len(t, actual.Resources, 4)
+	assert.Equal(t, resource.ReportResource{
+		Type:      "aws_vpc",
+		ID:        "vpc-2",
+		AccountID: "222222222222",
+		Region:    "eu-west-1",
+		Outcome:   resource.OutcomeFailed,
+		Error:     "DependencyViolation",
+	}, actual.Resources[2])
+
+	assert.Equal(t, resource.Totals{
+		resource.OutcomeDeleted:   2,
+		resource.OutcomeFailed:    1,
+		resource.OutcomeProtected: 1,
+	}, actual.Totals.All)
+	assert.Equal(t, map[string]resource.Totals{
+		"aws_vpc":    {resource.OutcomeDeleted: 1, resource.OutcomeFailed: 1, resource.OutcomeProtected: 1},
+		"aws_subnet": {resource.OutcomeDeleted: 1},
+	}, actual.Totals.ByType)
+	assert.Equal(t, map[string]resource.Totals{
+		"111111111111": {resource.OutcomeDeleted: 2, resource.OutcomeProtected: 1},
+		"222222222222": {resource.OutcomeFailed: 1},
+	}, actual.Totals.ByAccount)
+	assert.Equal(t, map[string]resource.Totals{
+		"us-west-2": {resource.OutcomeDeleted: 2, resource.OutcomeProtected: 1},
+		"eu-west-1": {resource.OutcomeFailed: 1},
+	}, actual.Totals.ByRegion)
+}
+
+func TestReport_AddUnreported(t *testing.T) {
+	vpc := terraform.Resource{Type: "aws_vpc", ID: "vpc-1", AccountID: "111111111111", Region: "us-west-2"}
+	otherRegion := terraform.Resource{Type: "aws_vpc", ID: "vpc-1", AccountID: "111111111111", Region: "eu-west-1"}
+
+	report := resource.NewReport(false)
+	report.Add(resource.OutcomeDeleted, vpc)
+
+	report.AddUnreported(resource.OutcomeSkipped, vpc, otherRegion)
+
+	require.Len(t, report.Resources, 2)
+	assert.Equal(t, resource.OutcomeDeleted, report.Resources[0].Outcome)
+	assert.Equal(t, "eu-west-1", report.Resources[1].Region)
+	assert.Equal(t, resource.OutcomeSkipped, report.Resources[1].Outcome)
+}
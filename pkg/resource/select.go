@@ -1,7 +1,10 @@
 package resource
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/apex/log"
 	"github.com/jckuester/awstools-lib/terraform"
@@ -9,9 +12,50 @@ import (
 	"github.com/zclconf/go-cty/cty/gocty"
 )
 
+// errNullValue means that the value of an attribute is null.
+var errNullValue = errors.New("attribute is null value")
+
+// errAttributeNotFound means that the state of a resource has no such attribute.
+var errAttributeNotFound = errors.New("attribute not found")
+
+// CriterionError means that a criterion of the filter cannot be evaluated for a resource,
+// e.g. because its tags cannot be read.
+type CriterionError struct {
+	Type      string
+	ID        string
+	Criterion string
+	Err       error
+}
+
+func (e CriterionError) Error() string {
+	return fmt.Sprintf("cannot filter resource (id=%s) by %s: %s", e.ID, e.Criterion, e.Err)
+}
+
 // Apply applies the filter to the given resources.
 func (f Filter) Apply(res []terraform.Resource) []terraform.Resource {
+	result, _ := f.apply(res, nil)
+
+	return result
+}
+
+// ApplyStrict applies the filter to the given resources like Apply, but also returns an error for each resource
+// that a criterion of the filter cannot be evaluated for (instead of just not matching the resource).
+func (f Filter) ApplyStrict(res []terraform.Resource) ([]terraform.Resource, []error) {
+	return f.apply(res, nil)
+}
+
+// apply applies the filter to the given resources, where accounts are matched by the given names as well.
+func (f Filter) apply(res []terraform.Resource, names AccountNames) ([]terraform.Resource, []error) {
+	var errs []error
+
 	for i, r := range res {
+		c := f.criteria(r.Type)
+
+		if c.created && r.CreatedAt == nil {
+			errs = append(errs, CriterionError{Type: r.Type, ID: r.ID, Criterion: "created",
+				Err: errors.New("creation date is unknown")})
+		}
+
 		tags, err := GetTags(&r)
 		if err != nil {
 			log.WithFields(log.Fields{
@@ -19,21 +63,35 @@ func (f Filter) Apply(res []terraform.Resource) []terraform.Resource {
 				"id":   r.ID,
 			}).WithError(err).Debug("failed to get tags")
 
+			if c.tags && !errors.Is(err, errNullValue) {
+				errs = append(errs, CriterionError{Type: r.Type, ID: r.ID, Criterion: "tags", Err: err})
+			}
+
 			continue
 		}
 
+		// the provider drops the tags from the state
+		for _, k := range []string{StackNameTag, StackIDTag} {
+			if v, ok := r.Tags[k]; ok {
+				if tags == nil {
+					tags = map[string]string{}
+				}
+				tags[k] = v
+			}
+		}
+
 		res[i].Tags = tags
 	}
 
 	var result []terraform.Resource
 
 	for _, r := range res {
-		if f.Match(r) {
+		if f.match(r, false, names) {
 			result = append(result, r)
 		}
 	}
 
-	return result
+	return result, errs
 }
 
 func GetTags(r *terraform.Resource) (map[string]string, error) {
@@ -53,11 +111,11 @@ func GetTags(r *terraform.Resource) (map[string]string, error) {
 
 	attrValue, ok := state.AsValueMap()["tags"]
 	if !ok {
-		return nil, fmt.Errorf("attribute not found: tags")
+		return nil, fmt.Errorf("%w: tags", errAttributeNotFound)
 	}
 
 	if attrValue.IsNull() {
-		return nil, fmt.Errorf("attribute is null value")
+		return nil, errNullValue
 	}
 
 	switch attrValue.Type() {
@@ -73,3 +131,74 @@ func GetTags(r *terraform.Resource) (map[string]string, error) {
 		return nil, fmt.Errorf("currently unhandled type: %s", attrValue.Type().FriendlyName())
 	}
 }
+
+// GetAttribute returns the value of a resource's Terraform state attribute as string.
+//
+// Nested attributes are accessed via a path of attribute names and list indexes separated by dots
+// (e.g. root_block_device.0.volume_size).
+func GetAttribute(r *terraform.Resource, path string) (string, error) {
+	if r == nil || r.UpdatableResource == nil {
+		return "", fmt.Errorf("resource is nil: %+v", r)
+	}
+
+	state := r.State()
+
+	if state == nil || state.IsNull() {
+		return "", fmt.Errorf("state is nil: %+v", state)
+	}
+
+	value := *state
+
+	for _, name := range strings.Split(path, ".") {
+		if value.IsNull() || !value.IsKnown() {
+			return "", fmt.Errorf("attribute not found: %s", path)
+		}
+
+		ty := value.Type()
+
+		switch {
+		case ty.IsObjectType():
+			if !ty.HasAttribute(name) {
+				return "", fmt.Errorf("attribute not found: %s", path)
+			}
+
+			value = value.GetAttr(name)
+		case ty.IsMapType():
+			key := cty.StringVal(name)
+			if !value.HasIndex(key).True() {
+				return "", fmt.Errorf("attribute not found: %s", path)
+			}
+
+			value = value.Index(key)
+		case ty.IsListType() || ty.IsTupleType() || ty.IsSetType():
+			i, err := strconv.Atoi(name)
+			if err != nil {
+				return "", fmt.Errorf("expected index of %s, got: %s", ty.FriendlyName(), name)
+			}
+
+			elems := value.AsValueSlice()
+			if i < 0 || i >= len(elems) {
+				return "", fmt.Errorf("attribute not found: %s", path)
+			}
+
+			value = elems[i]
+		default:
+			return "", fmt.Errorf("attribute not found: %s", path)
+		}
+	}
+
+	if value.IsNull() {
+		return "", errNullValue
+	}
+
+	switch value.Type() {
+	case cty.String:
+		return value.AsString(), nil
+	case cty.Number:
+		return value.AsBigFloat().Text('f', -1), nil
+	case cty.Bool:
+		return strconv.FormatBool(value.True()), nil
+	default:
+		return "", fmt.Errorf("currently unhandled type: %s", value.Type().FriendlyName())
+	}
+}
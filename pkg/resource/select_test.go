@@ -251,6 +251,102 @@ func TestYamlFilter_Apply_Created(t *testing.T) {
 	assert.Equal(t, "foo", result[0].ID)
 }
 
+func TestYamlFilter_ApplyStrict_UnknownCreationDate(t *testing.T) {
+	//given
+	f := &resource.Filter{
+		"aws_instance": {
+			{
+				Created: &resource.Created{
+					Before: &resource.CreatedTime{Time: time.Date(2018, 11, 20, 0, 0, 0, 0, time.UTC)},
+				},
+			},
+		},
+	}
+
+	res := []terraform.Resource{
+		{
+			Type:      "aws_instance",
+			ID:        "foo",
+			CreatedAt: aws.Time(time.Date(2018, 11, 17, 5, 0, 0, 0, time.UTC)),
+		},
+		{
+			Type: "aws_instance",
+			ID:   "bar",
+		},
+	}
+
+	// when
+	result, errs := f.ApplyStrict(res)
+
+	// then
+	require.Len(t, result, 1)
+	assert.Equal(t, "foo", result[0].ID)
+	require.Len(t, errs, 1)
+	assert.EqualError(t, errs[0], "cannot filter resource (id=bar) by created: creation date is unknown")
+}
+
+func TestYamlFilter_Apply_Expired(t *testing.T) {
+	//given
+	f := &resource.Filter{
+		"aws_instance": {
+			{
+				Expired: &resource.ExpiryFilter{Tag: "expires-at"},
+			},
+			{
+				Expired: &resource.ExpiryFilter{Tag: "ttl"},
+			},
+		},
+	}
+
+	now := time.Now().UTC()
+
+	res := []terraform.Resource{
+		{
+			Type: "aws_instance",
+			ID:   "expired-timestamp",
+			Tags: map[string]string{"expires-at": now.Add(-time.Hour).Format(time.RFC3339)},
+		},
+		{
+			Type: "aws_instance",
+			ID:   "expired-date",
+			Tags: map[string]string{"expires-at": "2018-11-20"},
+		},
+		{
+			Type:      "aws_instance",
+			ID:        "expired-ttl",
+			Tags:      map[string]string{"ttl": "2d"},
+			CreatedAt: aws.Time(now.AddDate(0, 0, -3)),
+		},
+		{
+			Type: "aws_instance",
+			ID:   "do-not-select-this1",
+			Tags: map[string]string{"expires-at": now.Add(time.Hour).Format(time.RFC3339)},
+		},
+		{
+			Type:      "aws_instance",
+			ID:        "do-not-select-this2",
+			Tags:      map[string]string{"ttl": "72h"},
+			CreatedAt: aws.Time(now.AddDate(0, 0, -2)),
+		},
+		{
+			Type: "aws_instance",
+			ID:   "do-not-select-this3",
+			Tags: map[string]string{"ttl": "1d"},
+		},
+		{
+			Type: "aws_instance",
+			ID:   "do-not-select-this4",
+			Tags: map[string]string{"expires-at": "never"},
+		},
+	}
+
+	// when
+	result := f.Apply(res)
+
+	// then
+	assert.Equal(t, []string{"expired-timestamp", "expired-date", "expired-ttl"}, ids(result))
+}
+
 func TestYamlFilter_Apply_CreatedBefore(t *testing.T) {
 	//given
 	f := &resource.Filter{
@@ -413,6 +509,282 @@ func TestYamlFilter_Apply_NegatedStringFilter(t *testing.T) {
 	assert.Equal(t, "select-this", result[0].ID)
 }
 
+func TestYamlFilter_Apply_FilterByAttributes(t *testing.T) {
+	//given
+	f := &resource.Filter{
+		"aws_instance": {
+			{
+				Attributes: map[string]resource.AttributeFilter{
+					"instance_type":                   {StringFilter: resource.StringFilter{Pattern: "^t2\\."}},
+					"root_block_device.0.volume_size": {Operator: ">", Value: 10},
+				},
+			},
+		},
+	}
+
+	newInstance := func(id, instanceType string, volumeSize int64) terraform.Resource {
+		state := cty.ObjectVal(map[string]cty.Value{
+			"id":            cty.StringVal(id),
+			"instance_type": cty.StringVal(instanceType),
+			"root_block_device": cty.ListVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{
+					"volume_size": cty.NumberIntVal(volumeSize),
+				}),
+			}),
+		})
+
+		return terraform.Resource{
+			Type:              "aws_instance",
+			ID:                id,
+			UpdatableResource: terradozerRes.NewWithState("aws_instance", id, nil, &state),
+		}
+	}
+
+	res := []terraform.Resource{
+		newInstance("select-this", "t2.micro", 20),
+		newInstance("wrong-instance-type", "t3.micro", 20),
+		newInstance("too-small", "t2.micro", 8),
+		{
+			Type: "aws_instance",
+			ID:   "no-state",
+		},
+	}
+
+	// when
+	result := f.Apply(res)
+
+	// then
+	require.Len(t, result, 1)
+	assert.Equal(t, "select-this", result[0].ID)
+}
+
+func TestYamlFilter_Apply_BooleanComposition(t *testing.T) {
+	//given
+	f := &resource.Filter{
+		"aws_instance": {
+			{
+				Tags: map[string]resource.StringFilter{
+					"env": {Pattern: "^dev$"},
+				},
+				Any: []resource.TypeFilter{
+					{
+						Created: &resource.Created{
+							Before: &resource.CreatedTime{Time: time.Date(2018, 11, 20, 0, 0, 0, 0, time.UTC)},
+						},
+					},
+					{
+						Not: &resource.TypeFilter{
+							Tags: map[string]resource.StringFilter{
+								"owner": {Pattern: ".*"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	res := []terraform.Resource{
+		{
+			Type:      "aws_instance",
+			ID:        "select-this-old",
+			Tags:      map[string]string{"env": "dev", "owner": "me"},
+			CreatedAt: aws.Time(time.Date(2018, 11, 17, 5, 0, 0, 0, time.UTC)),
+		},
+		{
+			Type:      "aws_instance",
+			ID:        "select-this-no-owner",
+			Tags:      map[string]string{"env": "dev"},
+			CreatedAt: aws.Time(time.Date(2018, 11, 22, 5, 0, 0, 0, time.UTC)),
+		},
+		{
+			Type:      "aws_instance",
+			ID:        "new-with-owner",
+			Tags:      map[string]string{"env": "dev", "owner": "me"},
+			CreatedAt: aws.Time(time.Date(2018, 11, 22, 5, 0, 0, 0, time.UTC)),
+		},
+		{
+			Type:      "aws_instance",
+			ID:        "wrong-env",
+			Tags:      map[string]string{"env": "prod"},
+			CreatedAt: aws.Time(time.Date(2018, 11, 17, 5, 0, 0, 0, time.UTC)),
+		},
+	}
+
+	// when
+	result := f.Apply(res)
+
+	// then
+	require.Len(t, result, 2)
+	assert.Equal(t, "select-this-old", result[0].ID)
+	assert.Equal(t, "select-this-no-owner", result[1].ID)
+}
+
+func TestYamlFilter_Apply_FilterByAccount(t *testing.T) {
+	//given
+	f := &resource.Filter{
+		"aws_instance": {
+			{
+				Account: &resource.StringFilter{Pattern: "^sandbox-"},
+			},
+		},
+		"aws_vpc": {
+			{
+				Account: &resource.StringFilter{Pattern: "^111111111111$", Negate: true},
+			},
+		},
+	}
+
+	res := []terraform.Resource{
+		{Type: "aws_instance", ID: "select-this", AccountID: "111111111111", Profile: "sandbox-1"},
+		{Type: "aws_instance", ID: "wrong-account", AccountID: "222222222222", Profile: "production"},
+		{Type: "aws_vpc", ID: "excluded-account", AccountID: "111111111111", Profile: "sandbox-1"},
+		{Type: "aws_vpc", ID: "select-this-too", AccountID: "222222222222", Profile: "sandbox-2"},
+	}
+
+	// when
+	result := f.Apply(res)
+
+	// then
+	require.Len(t, result, 2)
+	assert.Equal(t, "select-this", result[0].ID)
+	assert.Equal(t, "select-this-too", result[1].ID)
+}
+
+func TestYamlFilter_Apply_StackOwned(t *testing.T) {
+	//given
+	f := &resource.Filter{
+		"aws_instance": {},
+		"aws_vpc": {
+			{
+				ID: &resource.StringFilter{Pattern: "^foo"},
+			},
+			{
+				ID:                    &resource.StringFilter{Pattern: "^bar"},
+				IncludeCloudFormation: true,
+			},
+		},
+	}
+
+	stackTags := map[string]string{resource.StackNameTag: "my-stack"}
+
+	res := []terraform.Resource{
+		{Type: "aws_instance", ID: "select-this"},
+		{Type: "aws_instance", ID: "owned-by-stack1", Tags: stackTags},
+		{Type: "aws_vpc", ID: "foo-owned-by-stack2", Tags: stackTags},
+		{Type: "aws_vpc", ID: "bar-select-this-too", Tags: stackTags},
+		{Type: "aws_vpc", ID: "baz-do-not-select-this", Tags: stackTags},
+	}
+
+	// when
+	result := f.Apply(res)
+
+	// then
+	assert.Equal(t, []string{"select-this", "bar-select-this-too"}, ids(result))
+	assert.Equal(t, []string{"owned-by-stack1", "foo-owned-by-stack2"}, ids(f.StackOwned(res)))
+}
+
+func TestOwningStacks(t *testing.T) {
+	networkARN := "arn:aws:cloudformation:us-west-2:111111111111:stack/network/7e4a1f00-1b2c-11eb-9c6b-0a1b2c3d4e5f"
+
+	stackOwned := []terraform.Resource{
+		{Type: "aws_instance", ID: "i-1", AccountID: "111111111111", Region: "us-west-2",
+			Tags: map[string]string{resource.StackNameTag: "web"}},
+		{Type: "aws_vpc", ID: "vpc-1", AccountID: "111111111111", Region: "us-west-2",
+			Tags: map[string]string{resource.StackNameTag: "network", resource.StackIDTag: networkARN}},
+		{Type: "aws_instance", ID: "i-2", AccountID: "111111111111", Region: "us-west-2",
+			Tags: map[string]string{resource.StackNameTag: "web"}},
+	}
+
+	selected := []terraform.Resource{
+		{Type: "aws_cloudformation_stack", AccountID: "111111111111", Region: "us-west-2",
+			ID: "arn:aws:cloudformation:us-west-2:111111111111:stack/web/8c5a2f10-1b2c-11eb-9c6b-0a1b2c3d4e5f"},
+		{Type: "aws_cloudformation_stack", AccountID: "222222222222", Region: "us-west-2",
+			ID: "arn:aws:cloudformation:us-west-2:222222222222:stack/network/9d6b3f20-1b2c-11eb-9c6b-0a1b2c3d4e5f"},
+	}
+
+	stacks := resource.OwningStacks(stackOwned, selected)
+
+	require.Len(t, stacks, 2)
+
+	assert.Equal(t, "web", stacks[0].Name)
+	assert.True(t, stacks[0].Selected)
+	assert.Equal(t, []string{"i-1", "i-2"}, ids(stacks[0].Resources))
+
+	assert.Equal(t, "network", stacks[1].Name)
+	assert.Equal(t, networkARN, stacks[1].ID)
+	assert.False(t, stacks[1].Selected)
+	assert.Equal(t, []string{"vpc-1"}, ids(stacks[1].Resources))
+
+	// the stack cannot be deleted by its name
+	_, err := stacks[0].Resource(nil)
+	assert.EqualError(t, err, "unknown ARN of stack: web")
+}
+
+func TestTypeFilter_Match_All(t *testing.T) {
+	f := resource.TypeFilter{
+		All: []resource.TypeFilter{
+			{ID: &resource.StringFilter{Pattern: "^foo"}},
+			{ID: &resource.StringFilter{Pattern: "bar$"}},
+		},
+	}
+
+	assert.True(t, f.Match(terraform.Resource{ID: "foo-bar"}))
+	assert.False(t, f.Match(terraform.Resource{ID: "foo-baz"}))
+	assert.False(t, f.Match(terraform.Resource{ID: "baz-bar"}))
+}
+
+func TestTypeFilter_Match_InvalidRegex(t *testing.T) {
+	tf := resource.TypeFilter{ID: &resource.StringFilter{Pattern: "^foo("}}
+
+	assert.False(t, tf.Match(terraform.Resource{Type: "aws_instance", ID: "foo("}))
+}
+
+func TestGetAttribute(t *testing.T) {
+	state := cty.ObjectVal(map[string]cty.Value{
+		"engine":    cty.StringVal("postgres"),
+		"encrypted": cty.True,
+		"port":      cty.NumberIntVal(5432),
+		"tags":      cty.MapVal(map[string]cty.Value{"foo": cty.StringVal("bar")}),
+		"subnets":   cty.ListVal([]cty.Value{cty.StringVal("subnet-1")}),
+		"kms_key":   cty.NullVal(cty.String),
+	})
+
+	r := &terraform.Resource{
+		UpdatableResource: terradozerRes.NewWithState("aws_db_instance", "1234", nil, &state),
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr string
+	}{
+		{name: "string", path: "engine", want: "postgres"},
+		{name: "bool", path: "encrypted", want: "true"},
+		{name: "number", path: "port", want: "5432"},
+		{name: "map element", path: "tags.foo", want: "bar"},
+		{name: "list element", path: "subnets.0", want: "subnet-1"},
+		{name: "attribute not found", path: "foo", wantErr: "attribute not found: foo"},
+		{name: "index out of range", path: "subnets.1", wantErr: "attribute not found: subnets.1"},
+		{name: "invalid index", path: "subnets.foo", wantErr: "expected index of list of string, got: foo"},
+		{name: "null value", path: "kms_key", wantErr: "attribute is null value"},
+		{name: "unhandled type", path: "subnets", wantErr: "currently unhandled type: list of string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resource.GetAttribute(r, tt.path)
+
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestGetTags(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -0,0 +1,210 @@
+package resource
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jckuester/awstools-lib/terraform"
+	"gopkg.in/yaml.v3"
+)
+
+// Paths is a list of file paths, which can be given in yaml either as a single string or as a list of strings.
+type Paths []string
+
+// UnmarshalYAML decodes a single path or a list of paths.
+func (p *Paths) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*p = Paths{value.Value}
+		return nil
+	}
+
+	var paths []string
+
+	err := value.Decode(&paths)
+	if err != nil {
+		return err
+	}
+
+	*p = paths
+
+	return nil
+}
+
+// ManagedResources are the IDs of resources by type that are managed by Terraform (i.e., appear in a state file).
+type ManagedResources map[string]map[string]bool
+
+// tfState is the part of a Terraform state file (version 3 or 4) that is needed to find the managed resources.
+type tfState struct {
+	Version int `json:"version"`
+	// Resources are the resources of a state in version 4.
+	Resources []struct {
+		Mode      string `json:"mode"`
+		Type      string `json:"type"`
+		Instances []struct {
+			Attributes struct {
+				ID string `json:"id"`
+			} `json:"attributes"`
+		} `json:"instances"`
+	} `json:"resources"`
+	// Modules contain the resources of a state in version 3.
+	Modules []struct {
+		Resources map[string]struct {
+			Type    string `json:"type"`
+			Primary struct {
+				ID string `json:"id"`
+			} `json:"primary"`
+		} `json:"resources"`
+	} `json:"modules"`
+}
+
+// ReadTerraformStates reads the resources managed by Terraform from the given state files. If a path is a directory,
+// all files with suffix ".tfstate" in it are read. A directory without state files is an error,
+// so that a wrong path doesn't silently exclude nothing.
+func ReadTerraformStates(paths ...string) (ManagedResources, error) {
+	result := ManagedResources{}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		files := []string{path}
+
+		if info.IsDir() {
+			files, err = filepath.Glob(filepath.Join(path, "*.tfstate"))
+			if err != nil {
+				return nil, err
+			}
+
+			if len(files) == 0 {
+				return nil, fmt.Errorf("no Terraform state files (*.tfstate) found in directory: %s", path)
+			}
+
+			sort.Strings(files)
+		}
+
+		for _, file := range files {
+			err := result.read(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read Terraform state %s: %s", file, err)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// read adds the resources managed by a state file.
+func (m ManagedResources) read(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var state tfState
+
+	err = json.Unmarshal(data, &state)
+	if err != nil {
+		return err
+	}
+
+	switch state.Version {
+	case 3:
+		for _, module := range state.Modules {
+			for name, r := range module.Resources {
+				// data sources are not managed
+				if strings.HasPrefix(name, "data.") {
+					continue
+				}
+
+				m.add(r.Type, r.Primary.ID)
+			}
+		}
+	case 4:
+		for _, r := range state.Resources {
+			if r.Mode != "managed" {
+				continue
+			}
+
+			for _, instance := range r.Instances {
+				m.add(r.Type, instance.Attributes.ID)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported state version: %d", state.Version)
+	}
+
+	return nil
+}
+
+func (m ManagedResources) add(rType, id string) {
+	if rType == "" || id == "" {
+		return
+	}
+
+	if _, ok := m[rType]; !ok {
+		m[rType] = map[string]bool{}
+	}
+
+	m[rType][id] = true
+}
+
+// Len returns the number of managed resources.
+func (m ManagedResources) Len() int {
+	var result int
+	for _, ids := range m {
+		result += len(ids)
+	}
+
+	return result
+}
+
+// Contains checks whether a resource is managed by Terraform, i.e. its type and ID appear in a state.
+// Besides the ID, the id and arn attributes of the resource's state are matched (see selfIdentifiers),
+// since a resource can be listed by another ID than the one Terraform stores
+// (e.g., a Route53 zone is listed as /hostedzone/<id>, but stored as <id>).
+func (m ManagedResources) Contains(r terraform.Resource) bool {
+	ids, ok := m[r.Type]
+	if !ok {
+		return false
+	}
+
+	if ids[r.ID] {
+		return true
+	}
+
+	if r.UpdatableResource == nil {
+		return false
+	}
+
+	for _, id := range selfIdentifiers(r.UpdatableResource) {
+		if ids[id] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Exclude returns the given resources without the ones that are managed by Terraform.
+func (m ManagedResources) Exclude(res []terraform.Resource) []terraform.Resource {
+	if len(m) == 0 {
+		return res
+	}
+
+	var result []terraform.Resource
+
+	for _, r := range res {
+		if !m.Contains(r) {
+			result = append(result, r)
+		}
+	}
+
+	return result
+}
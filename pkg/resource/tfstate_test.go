@@ -0,0 +1,171 @@
+package resource_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jckuester/awstools-lib/terraform"
+	"github.com/jckuester/awsweeper/pkg/resource"
+	terradozerRes "github.com/jckuester/terradozer/pkg/resource"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const stateV4 = `{
+  "version": 4,
+  "resources": [
+    {
+      "mode": "managed",
+      "type": "aws_instance",
+      "name": "web",
+      "instances": [
+        {"attributes": {"id": "i-1"}},
+        {"attributes": {"id": "i-2"}}
+      ]
+    },
+    {
+      "mode": "data",
+      "type": "aws_vpc",
+      "name": "default",
+      "instances": [{"attributes": {"id": "vpc-1"}}]
+    }
+  ]
+}`
+
+const stateV3 = `{
+  "version": 3,
+  "modules": [
+    {
+      "resources": {
+        "aws_vpc.main": {"type": "aws_vpc", "primary": {"id": "vpc-2"}},
+        "data.aws_ami.ubuntu": {"type": "aws_ami", "primary": {"id": "ami-1"}}
+      }
+    }
+  ]
+}`
+
+func TestNewConfig_ExcludeTerraformState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "awsweeper")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	stateDir := filepath.Join(dir, "states")
+	require.NoError(t, os.Mkdir(stateDir, 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(stateDir, "web.tfstate"), []byte(stateV4), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(stateDir, "README.md"), []byte("not a state"), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "network.tfstate"), []byte(stateV3), 0600))
+
+	filterPath := filepath.Join(dir, "filter.yml")
+	require.NoError(t, ioutil.WriteFile(filterPath, []byte(`exclude_terraform_state:
+  - states
+  - network.tfstate
+aws_instance:
+aws_vpc:`), 0600))
+
+	cfg, err := resource.NewConfig(filterPath)
+	require.NoError(t, err)
+
+	assert.Equal(t, resource.ManagedResources{
+		"aws_instance": {"i-1": true, "i-2": true},
+		"aws_vpc":      {"vpc-2": true},
+	}, cfg.Managed)
+
+	res := []terraform.Resource{
+		{Type: "aws_instance", ID: "i-1"},
+		{Type: "aws_instance", ID: "i-3"},
+		{Type: "aws_vpc", ID: "vpc-1"},
+		{Type: "aws_vpc", ID: "vpc-2"},
+	}
+
+	assert.Equal(t, []terraform.Resource{
+		{Type: "aws_instance", ID: "i-3"},
+		{Type: "aws_vpc", ID: "vpc-1"},
+	}, cfg.Managed.Exclude(res))
+
+	cfg, err = resource.ParseConfig([]byte("exclude_terraform_state: terraform.tfstate"))
+	require.NoError(t, err)
+	assert.Equal(t, resource.Paths{"terraform.tfstate"}, cfg.ExcludeTerraformState)
+}
+
+func TestManagedResources_Contains(t *testing.T) {
+	managed := resource.ManagedResources{
+		"aws_route53_zone": {"Z1": true},
+		"aws_iam_policy":   {"arn:aws:iam::111111111111:policy/deploy": true},
+	}
+
+	withState := func(rType, id string, attrs map[string]cty.Value) terraform.Resource {
+		return terraform.Resource{
+			Type:              rType,
+			ID:                id,
+			UpdatableResource: terradozerRes.NewWithState(rType, id, nil, ctyValuePtr(cty.ObjectVal(attrs))),
+		}
+	}
+
+	tests := []struct {
+		name string
+		r    terraform.Resource
+		want bool
+	}{
+		{
+			name: "same ID",
+			r:    terraform.Resource{Type: "aws_route53_zone", ID: "Z1"},
+			want: true,
+		},
+		{
+			name: "same ID of other type",
+			r:    terraform.Resource{Type: "aws_vpc", ID: "Z1"},
+		},
+		{
+			name: "ID differs from id attribute without state",
+			r:    terraform.Resource{Type: "aws_route53_zone", ID: "/hostedzone/Z1"},
+		},
+		{
+			name: "id attribute",
+			r: withState("aws_route53_zone", "/hostedzone/Z1", map[string]cty.Value{
+				"id": cty.StringVal("Z1"),
+			}),
+			want: true,
+		},
+		{
+			name: "arn attribute",
+			r: withState("aws_iam_policy", "ANPA1", map[string]cty.Value{
+				"id":  cty.StringVal("ANPA1"),
+				"arn": cty.StringVal("arn:aws:iam::111111111111:policy/deploy"),
+			}),
+			want: true,
+		},
+		{
+			name: "no identifier in state",
+			r: withState("aws_iam_policy", "ANPA2", map[string]cty.Value{
+				"id":  cty.StringVal("ANPA2"),
+				"arn": cty.StringVal("arn:aws:iam::111111111111:policy/other"),
+			}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, managed.Contains(tt.r))
+		})
+	}
+}
+
+func TestReadTerraformStates_Errors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "awsweeper")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = resource.ReadTerraformStates(dir)
+	assert.EqualError(t, err, "no Terraform state files (*.tfstate) found in directory: "+dir)
+
+	path := filepath.Join(dir, "old.tfstate")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"version": 2}`), 0600))
+
+	_, err = resource.ReadTerraformStates(path)
+	assert.EqualError(t, err, "failed to read Terraform state "+path+": unsupported state version: 2")
+
+	_, err = resource.ReadTerraformStates(filepath.Join(dir, "missing.tfstate"))
+	assert.Error(t, err)
+}
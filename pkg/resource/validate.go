@@ -0,0 +1,359 @@
+package resource
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jckuester/awsls/resource"
+	"gopkg.in/yaml.v3"
+)
+
+// Severity is the severity of a diagnostic.
+type Severity string
+
+const (
+	// SeverityError means that the filter is invalid or that a filter entry can never match.
+	SeverityError Severity = "error"
+	// SeverityWarning means that the filter is valid, but likely not what was intended.
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is a problem found in a filter file.
+type Diagnostic struct {
+	File     string   `json:"file"`
+	Line     int      `json:"line,omitempty"`
+	Column   int      `json:"column,omitempty"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	pos := d.File
+	if d.Line > 0 {
+		pos += fmt.Sprintf(":%d", d.Line)
+	}
+	if d.Column > 0 {
+		pos += fmt.Sprintf(":%d", d.Column)
+	}
+
+	return fmt.Sprintf("%s: %s: %s", pos, d.Severity, d.Message)
+}
+
+// Diagnostics are the problems found in a filter file.
+type Diagnostics []Diagnostic
+
+// HasErrors returns true if any of the diagnostics is an error.
+func (ds Diagnostics) HasErrors() bool {
+	for _, d := range ds {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Print prints the diagnostics as text (one per line) or, if the output type is JSON, as a JSON array.
+func (ds Diagnostics) Print(outputType string) error {
+	if strings.ToLower(outputType) == "json" {
+		if ds == nil {
+			ds = Diagnostics{}
+		}
+
+		b, err := json.MarshalIndent(ds, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(b))
+
+		return nil
+	}
+
+	for _, d := range ds {
+		fmt.Println(d)
+	}
+
+	return nil
+}
+
+// yamlErrorLine matches the line number in errors of the yaml parser and decoder.
+var yamlErrorLine = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// ValidateFile checks a filter file for problems without listing any resources (i.e., no AWS credentials are needed).
+func ValidateFile(path string) (Diagnostics, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ValidateConfig(path, data), nil
+}
+
+// ValidateConfig checks the content of a filter file for problems. Other than Config.Validate,
+// it doesn't stop at the first problem and also reports filter entries that are likely not what was intended.
+func ValidateConfig(file string, data []byte) Diagnostics {
+	var result Diagnostics
+
+	add := func(line, column int, severity Severity, format string, a ...interface{}) {
+		result = append(result, Diagnostic{
+			File:     file,
+			Line:     line,
+			Column:   column,
+			Severity: severity,
+			Message:  fmt.Sprintf(format, a...),
+		})
+	}
+
+	var doc yaml.Node
+
+	err := yaml.Unmarshal(data, &doc)
+	if err != nil {
+		line, msg := splitYAMLError(err.Error())
+		add(line, 0, SeverityError, "%s", msg)
+
+		return result
+	}
+
+	cfg, err := ParseConfig(data)
+	if err != nil {
+		if typeErr, ok := err.(*yaml.TypeError); ok {
+			for _, e := range typeErr.Errors {
+				line, msg := splitYAMLError(e)
+				add(line, 0, SeverityError, "%s", msg)
+			}
+		} else {
+			line, msg := splitYAMLError(err.Error())
+			add(line, 0, SeverityError, "%s", msg)
+		}
+	}
+
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return result
+	}
+
+	// unsupported criteria make a filter entry never match, which is only an error in strict mode (see Config.Validate)
+	unsupported := SeverityWarning
+	if mappingValue(doc.Content[0], "strict") == "true" {
+		unsupported = SeverityError
+	}
+
+	content := doc.Content[0].Content
+	for i := 0; i < len(content); i += 2 {
+		key, value := content[i], content[i+1]
+
+		rType := key.Value
+		if !strings.HasPrefix(rType, "aws_") {
+			continue
+		}
+
+		if !resource.IsSupportedType(rType) || rType == "aws_kms_alias" {
+			msg := fmt.Sprintf("unsupported resource type: %s", rType)
+			if suggestion := suggestType(rType); suggestion != "" {
+				msg += fmt.Sprintf(" (did you mean %s?)", suggestion)
+			}
+
+			add(key.Line, key.Column, SeverityError, "%s", msg)
+
+			continue
+		}
+
+		if value.Kind != yaml.SequenceNode {
+			continue
+		}
+
+		// entries maps the yaml of an entry to the line of its first occurrence
+		entries := map[string]int{}
+
+		for _, entry := range value.Content {
+			for _, d := range validateEntry(rType, entry, false, unsupported) {
+				d.File = file
+				result = append(result, d)
+			}
+
+			b, err := yaml.Marshal(entry)
+			if err != nil {
+				continue
+			}
+
+			if line, ok := entries[string(b)]; ok {
+				add(entry.Line, entry.Column, SeverityWarning, "duplicate entry of %s (same as entry at line %d)",
+					rType, line)
+				continue
+			}
+
+			entries[string(b)] = entry.Line
+		}
+	}
+
+	// Config.Validate stops at the first problem, which is already reported above in more detail if it's
+	// a problem of the filter; otherwise, it's a problem of the options (e.g., max_deletions or protect).
+	if cfg != nil && !result.HasErrors() {
+		err = cfg.Validate()
+		if err != nil {
+			add(0, 0, SeverityError, "%s", err)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Line < result[j].Line
+	})
+
+	return result
+}
+
+// validateEntry checks a filter entry (and its nested all, any and not entries) of a resource type
+// for criteria that the type doesn't support (reported with the given severity), for created ranges
+// that are empty, and for options that are not allowed in nested entries.
+func validateEntry(rType string, entry *yaml.Node, nested bool, unsupported Severity) Diagnostics {
+	var result Diagnostics
+
+	if entry.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	add := func(node *yaml.Node, severity Severity, format string, a ...interface{}) {
+		result = append(result, Diagnostic{
+			Line:     node.Line,
+			Column:   node.Column,
+			Severity: severity,
+			Message:  fmt.Sprintf(format, a...),
+		})
+	}
+
+	for i := 0; i < len(entry.Content); i += 2 {
+		key, value := entry.Content[i], entry.Content[i+1]
+
+		switch key.Value {
+		case "tags", "tagged", "expired":
+			if !resource.SupportsTags(rType) {
+				add(key, unsupported, "%s doesn't support tags, so it cannot be filtered by %s", rType, key.Value)
+			}
+		case "created":
+			if !SupportsCreationDate(rType) {
+				add(key, unsupported, "%s has no creation date, so it cannot be filtered by created", rType)
+				continue
+			}
+
+			var created Created
+			if err := value.Decode(&created); err != nil {
+				// already reported when parsing the config
+				continue
+			}
+
+			if created.Before != nil && created.After != nil && !created.After.Before(created.Before.Time) {
+				add(key, SeverityError, "created after (%s) is not before created before (%s), so no resource can match",
+					mappingValue(value, "after"), mappingValue(value, "before"))
+			}
+		case "all", "any":
+			if value.Kind != yaml.SequenceNode {
+				continue
+			}
+
+			for _, nested := range value.Content {
+				result = append(result, validateEntry(rType, nested, true, unsupported)...)
+			}
+		case "not":
+			result = append(result, validateEntry(rType, value, true, unsupported)...)
+		case "include_cloudformation":
+			if nested {
+				add(key, SeverityError, "include_cloudformation can only be set at the top level of a filter entry")
+			}
+		}
+	}
+
+	return result
+}
+
+// mappingValue returns the scalar value of a key in a yaml mapping.
+func mappingValue(node *yaml.Node, key string) string {
+	for i := 0; i < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1].Value
+		}
+	}
+
+	return ""
+}
+
+// splitYAMLError splits an error message of the yaml parser or decoder into the line number (0 if there is none)
+// and the remaining message.
+func splitYAMLError(msg string) (int, string) {
+	m := yamlErrorLine.FindStringSubmatch(msg)
+	if m == nil {
+		return 0, msg
+	}
+
+	line, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, msg
+	}
+
+	return line, m[2]
+}
+
+// suggestType returns the supported resource type that is most similar to the given one
+// (or an empty string if none is similar enough).
+func suggestType(rType string) string {
+	var suggestion string
+
+	minDistance := len(rType)/3 + 1
+
+	for _, t := range resource.SupportedTypes {
+		if t == rType {
+			continue
+		}
+
+		d := levenshtein(rType, t)
+		if d < minDistance {
+			minDistance = d
+			suggestion = t
+		}
+	}
+
+	return suggestion
+}
+
+// levenshtein returns the edit distance between two strings.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
+
+// minInt returns the smallest of the given values.
+func minInt(values ...int) int {
+	result := values[0]
+	for _, v := range values[1:] {
+		if v < result {
+			result = v
+		}
+	}
+
+	return result
+}
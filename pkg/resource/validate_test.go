@@ -0,0 +1,105 @@
+package resource_test
+
+import (
+	"testing"
+
+	"github.com/jckuester/awsweeper/pkg/resource"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  resource.Diagnostics
+	}{
+		{
+			name:  "valid filter",
+			input: "aws_instance:\n  - id: ^foo\n    created:\n      before: 7d\naws_vpc:",
+		},
+		{
+			name:  "syntax error",
+			input: "aws_instance:\n  - id: ^foo\n - id: ^bar",
+			want: resource.Diagnostics{
+				{File: "filter.yml", Line: 2, Severity: resource.SeverityError,
+					Message: "did not find expected key"},
+			},
+		},
+		{
+			name:  "unsupported resource type with suggestion",
+			input: "aws_instanse:\naws_foo_bar_baz:",
+			want: resource.Diagnostics{
+				{File: "filter.yml", Line: 1, Column: 1, Severity: resource.SeverityError,
+					Message: "unsupported resource type: aws_instanse (did you mean aws_instance?)"},
+				{File: "filter.yml", Line: 2, Column: 1, Severity: resource.SeverityError,
+					Message: "unsupported resource type: aws_foo_bar_baz"},
+			},
+		},
+		{
+			name:  "unsupported criteria",
+			input: "aws_vpc:\n  - not:\n      created:\n        before: 7d\naws_iam_policy_attachment:\n  - tagged: true",
+			want: resource.Diagnostics{
+				{File: "filter.yml", Line: 3, Column: 7, Severity: resource.SeverityWarning,
+					Message: "aws_vpc has no creation date, so it cannot be filtered by created"},
+				{File: "filter.yml", Line: 5, Column: 1, Severity: resource.SeverityError,
+					Message: "unsupported resource type: aws_iam_policy_attachment"},
+			},
+		},
+		{
+			name:  "unsupported criteria in strict mode",
+			input: "strict: true\naws_vpc:\n  - created:\n      before: 7d",
+			want: resource.Diagnostics{
+				{File: "filter.yml", Line: 3, Column: 5, Severity: resource.SeverityError,
+					Message: "aws_vpc has no creation date, so it cannot be filtered by created"},
+			},
+		},
+		{
+			name:  "invalid max_deletions",
+			input: "aws_vpc:\nmax_deletions:\n  aws_vpc: 0",
+			want: resource.Diagnostics{
+				{File: "filter.yml", Severity: resource.SeverityError,
+					Message: "max_deletions of aws_vpc must be greater than 0"},
+			},
+		},
+		{
+			name:  "invalid protect rule",
+			input: "aws_vpc:\nprotect:\n  - {}",
+			want: resource.Diagnostics{
+				{File: "filter.yml", Severity: resource.SeverityError,
+					Message: "protect rule 1 has no criteria"},
+			},
+		},
+		{
+			name:  "invalid created time and empty created range",
+			input: "aws_instance:\n  - created:\n      before: yesterday\n  - created:\n      before: 7d\n      after: 1d",
+			want: resource.Diagnostics{
+				{File: "filter.yml", Line: 3, Severity: resource.SeverityError,
+					Message: "invalid created time: yesterday"},
+				{File: "filter.yml", Line: 4, Column: 5, Severity: resource.SeverityError,
+					Message: "created after (1d) is not before created before (7d), so no resource can match"},
+			},
+		},
+		{
+			name:  "nested include_cloudformation",
+			input: "aws_instance:\n  - include_cloudformation: true\n  - not:\n      include_cloudformation: true",
+			want: resource.Diagnostics{
+				{File: "filter.yml", Line: 4, Column: 7, Severity: resource.SeverityError,
+					Message: "include_cloudformation can only be set at the top level of a filter entry"},
+			},
+		},
+		{
+			name:  "duplicate entry",
+			input: "aws_instance:\n  - tags:\n      env: dev\n  - id: ^foo\n  - tags:\n      env: dev",
+			want: resource.Diagnostics{
+				{File: "filter.yml", Line: 5, Column: 5, Severity: resource.SeverityWarning,
+					Message: "duplicate entry of aws_instance (same as entry at line 2)"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resource.ValidateConfig("filter.yml", []byte(tt.input))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}